@@ -24,9 +24,12 @@ package cmd
 import (
 	"PackageManager/internal"
 	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -35,26 +38,39 @@ import (
 
 const max_pack_file_size = 1 << 31
 
+// targets of packages given as positional arguments, shared by create and update
+var targetPaths []string
+var targetExcludes []string
+var targetStripPrefix string
+var targetDest string
+var targetFormat string
+var reproducible bool
+var delta bool
+var packetTags []string
+
 // createCmd represents the create command
 var createCmd = &cobra.Command{
-	Use:   "create",
+	Use:   "create [name@version...]",
 	Short: "create a new package",
+	Example: `  PackageManager create -p packet.json -f config.json
+  PackageManager create packet-1@1.10 --path 'test/*' --exclude '*.ext' --exclude '*.tmp' -f config.json
+  PackageManager create packet-1@1.11 --path 'test/*' --format tar.zst -f config.json
+  PackageManager create packet-1@1.12 --path 'test/*' --tag latest --tag beta -f config.json`,
 	Run: func(cmd *cobra.Command, args []string) {
 		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
 		if !ok {
 			cobra.CheckErr("remote-client is not a valid remote client")
 		}
 		log.Println("creating a new package...")
-		err := rClient.Create(models.Create(getPack()))
-		if err != nil {
-			log.Println(tracerr.Sprint(err))
-		}
+		printResults(rClient.Create(models.Create(getPack(cmd, args))))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(createCmd)
 
+	addTargetFlags(createCmd)
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -66,7 +82,78 @@ func init() {
 	// createCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-func getPack() models.Pack {
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&targetPaths, "path", nil, "glob of files to pack for packages given as arguments (repeatable)")
+	cmd.Flags().StringSliceVar(&targetExcludes, "exclude", nil, "glob of file names to exclude for packages given as arguments (repeatable)")
+	cmd.Flags().StringVar(&targetStripPrefix, "strip-prefix", "", "local path prefix removed from archive paths for packages given as arguments")
+	cmd.Flags().StringVar(&targetDest, "dest", "", "archive directory files are stored under for packages given as arguments")
+	cmd.Flags().StringVar(&targetFormat, "format", "", "archive format for packages given as arguments: "+strings.Join(models.ArchiveFormats, ", ")+" (default zip)")
+	cmd.Flags().BoolVar(&reproducible, "reproducible", false, "build every package reproducibly (sorted entries, fixed times, modes and compression)")
+	cmd.Flags().BoolVar(&delta, "delta", false, "upload every package as a delta of the closest lower published version")
+	cmd.Flags().StringArrayVar(&packetTags, "tag", nil, "point this tag of every package at the published version, e.g. latest (repeatable)")
+}
+
+// getPack returns the packets given as name@version arguments merged with the
+// packet.json file. The file is read when no arguments are given or when it
+// is set explicitly; arguments replace file packets with the same name@version.
+func getPack(cmd *cobra.Command, args []string) models.Pack {
+	pack := models.Pack{}
+	if len(args) == 0 || cmd.Flags().Changed("pack") {
+		pack = readPackFile()
+	}
+	for _, arg := range args {
+		packet, err := packetFromArg(arg)
+		checkErr(err)
+		pack.Packets = mergePacket(pack.Packets, packet)
+	}
+	if len(pack.Packets) == 0 {
+		checkErr(fmt.Errorf("%w: no packages to create", models.ErrInvalidInput))
+	}
+	for i := range pack.Packets {
+		pack.Packets[i].Reproducible = pack.Packets[i].Reproducible || reproducible
+		pack.Packets[i].Delta = pack.Packets[i].Delta || delta
+		pack.Packets[i].Tags = append(pack.Packets[i].Tags, packetTags...)
+	}
+	return pack
+}
+
+func packetFromArg(arg string) (models.Packets, error) {
+	ref := models.ParsePackages(arg)
+	if ref.Name == "" || ref.Ver == "" {
+		return models.Packets{}, fmt.Errorf("%w: package %q must be given as name@version", models.ErrInvalidInput, arg)
+	}
+	if op, ver := utils.ParseVersionRef(ref.Ver); op != utils.EQUAL || ver != ref.Ver {
+		return models.Packets{}, fmt.Errorf("%w: package %q needs an exact version", models.ErrInvalidInput, arg)
+	}
+	if len(targetPaths) == 0 {
+		return models.Packets{}, fmt.Errorf("%w: package %q needs at least one --path", models.ErrInvalidInput, arg)
+	}
+	packet := models.Packets{Name: ref.Name, Ver: ref.Ver, Format: targetFormat}
+	if _, err := packet.ArchiveFormat(); err != nil {
+		return models.Packets{}, err
+	}
+	for _, p := range targetPaths {
+		packet.Targets = append(packet.Targets, models.Targets{
+			Path:        p,
+			Excludes:    targetExcludes,
+			StripPrefix: targetStripPrefix,
+			Dest:        targetDest,
+		})
+	}
+	return packet, nil
+}
+
+func mergePacket(packets []models.Packets, packet models.Packets) []models.Packets {
+	for i, p := range packets {
+		if p.Name == packet.Name && p.Ver == packet.Ver {
+			packets[i] = packet
+			return packets
+		}
+	}
+	return append(packets, packet)
+}
+
+func readPackFile() models.Pack {
 	packFile, ok := viper.Get("pack").(*string)
 	if !ok {
 		cobra.CheckErr("pack is not a string")
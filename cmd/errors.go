@@ -0,0 +1,90 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal/models"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Exit codes returned by the CLI, so scripts can react to the kind of failure.
+const (
+	exitOK = iota
+	exitGeneric
+	exitInvalidInput
+	exitNotFound
+	exitVersionExists
+	exitIntegrity
+	exitAuth
+	exitPermission
+	exitTransport
+	exitConflict
+)
+
+var exitCodes = []struct {
+	err  error
+	code int
+	kind string
+}{
+	{models.ErrInvalidInput, exitInvalidInput, "invalid_input"},
+	{models.ErrNotFound, exitNotFound, "not_found"},
+	{models.ErrVersionExists, exitVersionExists, "version_exists"},
+	{models.ErrIntegrity, exitIntegrity, "integrity"},
+	{models.ErrAuth, exitAuth, "auth"},
+	{models.ErrPermission, exitPermission, "permission"},
+	{models.ErrTransport, exitTransport, "transport"},
+	{models.ErrConflict, exitConflict, "conflict"},
+}
+
+// exitCode maps an error onto the exit code of its models error kind.
+func exitCode(err error) int {
+	code, _ := classify(err)
+	return code
+}
+
+func classify(err error) (int, string) {
+	if err == nil {
+		return exitOK, ""
+	}
+	for _, c := range exitCodes {
+		if errors.Is(err, c.err) {
+			return c.code, c.kind
+		}
+	}
+	return exitGeneric, "error"
+}
+
+// checkErr works like cobra.CheckErr but exits with the code mapped from err
+// and prints the error in the selected output format.
+func checkErr(err error) {
+	if err == nil {
+		return
+	}
+	if outputFormat() == formatJson {
+		printJson(newReport(nil, err))
+	} else {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+	os.Exit(exitCode(err))
+}
@@ -29,10 +29,18 @@ import (
 	"github.com/spf13/viper"
 )
 
+var fetchDest *string
+var fetchSync *bool
+var fetchForce *bool
+
 // fetchCmd represents the fetch command
 var fetchCmd = &cobra.Command{
-	Use:   "fetch",
+	Use:   "fetch [name@range...]",
 	Short: "download exist package from storage",
+	Example: `  PackageManager fetch -u packages.json -f config.json
+  PackageManager fetch 'packet-1@>=1.2' packet-2@3.0 -f config.json
+  PackageManager fetch packet-1 --dest '{name}/{version}' -o output -f config.json
+  PackageManager fetch --sync -u packages.json -o output -f config.json`,
 	Run: func(cmd *cobra.Command, args []string) {
 		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
 		if !ok {
@@ -44,16 +52,28 @@ var fetchCmd = &cobra.Command{
 			cobra.CheckErr("output is not a string")
 		}
 
-		err := rClient.Download(models.Read(getUnpack()), *output)
-		if err != nil {
-			cobra.CheckErr(err)
+		unpack := getUnpack(cmd, args)
+		for i := range unpack.Packages {
+			if unpack.Packages[i].Dest == "" {
+				unpack.Packages[i].Dest = *fetchDest
+			}
+		}
+
+		if *fetchSync {
+			printResults(rClient.Sync(models.Read(unpack), *output, *fetchForce))
+			return
 		}
+		printResults(rClient.Download(models.Read(unpack), *output))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(fetchCmd)
 
+	fetchDest = fetchCmd.Flags().String("dest", "", "install directory below output for packages without their own dest, may use {name} and {version}")
+	fetchSync = fetchCmd.Flags().Bool("sync", false, "make output hold exactly the given packages, removing files of replaced and unlisted ones")
+	fetchForce = fetchCmd.Flags().Bool("force", false, "with --sync, overwrite and remove locally modified files")
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -0,0 +1,99 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var gcPolicy *string
+var gcLockfiles *[]string
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "remove the versions the retention rules do not keep",
+	Example: `  PackageManager gc --policy gc.json --dry-run -f config.json
+  PackageManager gc --policy gc.json --lockfile app/packages.json --lockfile /opt/app/.pm/installed.json -f config.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+		if !ok {
+			cobra.CheckErr("remote-client is not a valid remote client")
+		}
+
+		policy := models.GCPolicy{}
+		readJsonFile(*gcPolicy, &policy)
+		locked := make([]models.LockedPackage, 0)
+		for _, lockfile := range append(policy.Lockfiles, *gcLockfiles...) {
+			lock := models.Lockfile{}
+			readJsonFile(lockfile, &lock)
+			locked = append(locked, lock.Packages...)
+		}
+
+		results, err := rClient.GC(policy, locked)
+		printReport(results, err, func() {
+			printResultLines(results)
+			var reclaimed int64
+			for _, r := range results {
+				reclaimed += r.Bytes
+			}
+			verb := "reclaimed"
+			if *viper.Get("dry-run").(*bool) {
+				verb = "would be reclaimed"
+			}
+			fmt.Printf("%d versions, %d bytes %s\n", len(results), reclaimed, verb)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	gcPolicy = gcCmd.Flags().String("policy", "gc.json", "retention rules, see README")
+	gcLockfiles = gcCmd.Flags().StringArray("lockfile", nil, "packages.json or installed.json whose versions are kept, may be repeated")
+}
+
+// readJsonFile decodes the json file into v.
+func readJsonFile(file string, v interface{}) {
+	stat, err := os.Stat(file)
+	if err != nil {
+		checkErr(fmt.Errorf("%w: %w", models.ErrInvalidInput, err))
+	}
+	if stat.Size() > max_pack_file_size {
+		checkErr(fmt.Errorf("%w: %s is too large", models.ErrInvalidInput, file))
+	}
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		checkErr(err)
+	}
+	if err = json.Unmarshal(bs, v); err != nil {
+		checkErr(fmt.Errorf("%w: %s: %w", models.ErrInvalidInput, file, err))
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:     "info <name>@<range>",
+	Short:   "show metadata and contents of a package version",
+	Example: `  PackageManager info 'packet-1@>=1.2' -f config.json`,
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+		if !ok {
+			cobra.CheckErr("remote-client is not a valid remote client")
+		}
+		ref := models.ParsePackages(args[0])
+		details, err := rClient.Info(ref.Name, ref.Ver)
+		printReport(details, err, func() {
+			if err == nil {
+				printDetails(details)
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
+
+func printDetails(d models.PackageDetails) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "package:\t%s@%s\n", d.Name, d.Version)
+	fmt.Fprintf(w, "digest:\t%s\n", d.Digest)
+	fmt.Fprintf(w, "size:\t%d bytes\n", d.Size)
+	if d.Format != "" {
+		fmt.Fprintf(w, "format:\t%s\n", d.Format)
+	}
+	if d.Base != "" {
+		fmt.Fprintf(w, "delta of:\t%s@%s\n", d.Name, d.Base)
+	}
+	fmt.Fprintf(w, "uploaded:\t%s\n", d.Uploaded.Format(time.DateTime))
+	if d.Yanked {
+		fmt.Fprintln(w, "yanked:\tyes, only installed when pinned exactly")
+	}
+	if d.Manifest != nil {
+		fmt.Fprintf(w, "created:\t%s\n", d.Manifest.Created.Format(time.DateTime))
+	}
+	fmt.Fprintln(w, "dependencies:")
+	for _, dep := range d.Dependencies {
+		fmt.Fprintf(w, "\t%s@%s\n", dep.Name, dep.Ver)
+	}
+	fmt.Fprintf(w, "files (%d):\n", len(d.Files))
+	for _, f := range d.Files {
+		fmt.Fprintf(w, "\t%s\t%s\t%d bytes\t%s\n", f.Mode, f.Path, f.Size, f.ModTime.Format(time.DateTime))
+	}
+	w.Flush()
+}
@@ -0,0 +1,70 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// installedCmd represents the installed command
+var installedCmd = &cobra.Command{
+	Use:         "installed",
+	Short:       "list packages installed into the output directory",
+	Example:     `  PackageManager installed -o output`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{annotationLocal: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+		if !ok {
+			cobra.CheckErr("remote-client is not a valid remote client")
+		}
+		output, ok := viper.Get("output").(*string)
+		if !ok {
+			cobra.CheckErr("output is not a string")
+		}
+		installed, err := rClient.Installed(*output)
+		printReport(installed, err, func() {
+			printInstalled(installed)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(installedCmd)
+}
+
+func printInstalled(installed []models.InstalledPackage) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, p := range installed {
+		fmt.Fprintf(w, "%s@%s\t%s\t%d files\t%s\t%s\n", p.Name, p.Version, p.Dir, len(p.Files),
+			p.Installed.Format(time.DateTime), p.Digest)
+	}
+	w.Flush()
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var locksBreak *[]string
+var locksBreakStale *bool
+
+// locksCmd represents the locks command
+var locksCmd = &cobra.Command{
+	Use:   "locks",
+	Short: "list and break the locks held on the storage",
+	Example: `  PackageManager locks -f config.json
+  PackageManager locks --break 'packet-1@1.2' -f config.json
+  PackageManager locks --break-stale -f config.json`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+		if !ok {
+			cobra.CheckErr("remote-client is not a valid remote client")
+		}
+		if len(*locksBreak) == 0 && !*locksBreakStale {
+			locks, err := rClient.Locks()
+			printReport(locks, err, func() {
+				printLocks(locks)
+			})
+			return
+		}
+		broken, err := rClient.BreakLocks(*locksBreak, *locksBreakStale)
+		printReport(broken, err, func() {
+			for _, lock := range broken {
+				fmt.Printf("lock %s of %s@%s pid %d was broken\n", lock.Name, lock.Owner, lock.Host, lock.Pid)
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(locksCmd)
+
+	locksBreak = locksCmd.Flags().StringArray("break", nil, "remove the named lock whoever holds it, can be repeated")
+	locksBreakStale = locksCmd.Flags().Bool("break-stale", false, "remove every lock that expired")
+}
+
+func printLocks(locks []models.Lock) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	now := time.Now()
+	for _, l := range locks {
+		state := "held"
+		if l.Stale(now) {
+			state = "stale"
+		}
+		fmt.Fprintf(w, "%s\t%s@%s\tpid %d\tsince %s\texpires %s\t%s\n", l.Name, l.Owner, l.Host, l.Pid,
+			l.Created.Format(time.DateTime), l.Expires.Format(time.DateTime), state)
+	}
+	w.Flush()
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal/configs"
+	"PackageManager/internal/models"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var mirrorFrom *string
+var mirrorTo *string
+var mirrorVersion *string
+var mirrorState *string
+
+// mirrorCmd represents the mirror command
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror [<pattern>] --from <repository> --to <repository>",
+	Short: "replicate packages between configured repositories, copying only missing or changed versions",
+	Example: `  PackageManager mirror --from prod --to backup -f config.json
+  PackageManager mirror "packet-*" --version ">=1.0" --from prod --to backup --workers 4 -f config.json`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{annotationRepositories: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		sshConfig, ok := viper.Get("ssh-config").(*configs.SSHConfig)
+		if !ok {
+			cobra.CheckErr("ssh-config is not a valid ssh config")
+		}
+		if *mirrorFrom == *mirrorTo {
+			checkErr(fmt.Errorf("%w: --from and --to name the same repository", models.ErrInvalidInput))
+		}
+		fromConfig, err := sshConfig.Repository(*mirrorFrom)
+		checkErr(err)
+		toConfig, err := sshConfig.Repository(*mirrorTo)
+		checkErr(err)
+		from, err := newRemoteClient(fromConfig)
+		checkErr(err)
+		defer from.Close()
+		to, err := newRemoteClient(toConfig)
+		checkErr(err)
+		defer to.Close()
+
+		opts := models.MirrorOptions{
+			Pattern:     "*",
+			Constraint:  *mirrorVersion,
+			StateFile:   *mirrorState,
+			Source:      *mirrorFrom,
+			Destination: *mirrorTo,
+		}
+		if len(args) > 0 {
+			opts.Pattern = args[0]
+		}
+		if opts.StateFile == "" {
+			if cacheDir := *viper.Get("cache-dir").(*string); cacheDir != "" {
+				opts.StateFile = filepath.Join(cacheDir, "mirror", *mirrorFrom+"-"+*mirrorTo+".json")
+			}
+		}
+		printResults(from.Mirror(to, opts))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mirrorCmd)
+
+	mirrorFrom = mirrorCmd.Flags().String("from", "", "repository to copy from, as named in the repositories of the config")
+	mirrorTo = mirrorCmd.Flags().String("to", "", "repository to copy to, as named in the repositories of the config")
+	mirrorVersion = mirrorCmd.Flags().String("version", "", "version range of the versions to copy (default all)")
+	mirrorState = mirrorCmd.Flags().String("state", "", "file keeping the progress so an interrupted mirror resumes (default in the cache dir)")
+	mirrorCmd.Flags().IntVar(&workerNum, "workers", 1, "how many packages are copied at once")
+	mirrorCmd.MarkFlagRequired("from")
+	mirrorCmd.MarkFlagRequired("to")
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	formatText = "text"
+	formatJson = "json"
+)
+
+type report struct {
+	Status  string       `json:"status"`
+	Results interface{}  `json:"results"`
+	Error   *reportError `json:"error,omitempty"`
+}
+
+type reportError struct {
+	Kind    string `json:"kind"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Op      string `json:"op,omitempty"`
+	Package string `json:"package,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+func newReport(results interface{}, err error) report {
+	if results == nil {
+		results = []struct{}{}
+	}
+	r := report{Status: models.StatusOk, Results: results}
+	if err == nil {
+		return r
+	}
+	code, kind := classify(err)
+	r.Status = "error"
+	r.Error = &reportError{Kind: kind, Code: code, Message: err.Error()}
+	var pkgErr *models.PackageError
+	if errors.As(err, &pkgErr) {
+		r.Error.Op = pkgErr.Op
+		r.Error.Package = pkgErr.Name
+		r.Error.Version = pkgErr.Version
+	}
+	return r
+}
+
+func outputFormat() string {
+	format, ok := viper.Get("output-format").(*string)
+	if !ok {
+		return formatText
+	}
+	return *format
+}
+
+func validateOutputFormat() {
+	switch outputFormat() {
+	case formatText, formatJson:
+	default:
+		checkErr(fmt.Errorf("%w: unknown output format %q, use text or json", models.ErrInvalidInput, outputFormat()))
+	}
+}
+
+// printResults writes the command results in the selected output format and
+// exits with the mapped code when err is set.
+func printResults(results []models.Result, err error) {
+	printReport(results, err, func() {
+		printResultLines(results)
+	})
+}
+
+// printResultLines writes one line per result in text mode.
+func printResultLines(results []models.Result) {
+	for _, r := range results {
+		if r.Status == models.StatusDryRun {
+			printPlanned(r)
+			continue
+		}
+		line := fmt.Sprintf("package: %s@%s with %d files (%d bytes) was %s in %dms", r.Package, r.Version, r.Files, r.Bytes, r.Action, r.DurationMs)
+		if r.Digest != "" {
+			line += ", digest " + r.Digest
+		}
+		fmt.Println(line)
+	}
+}
+
+// printPlanned writes what a dry run would do, with the files involved.
+func printPlanned(r models.Result) {
+	fmt.Printf("package: %s@%s with %d files (%d bytes) would be %s: %s\n", r.Package, r.Version, r.Files, r.Bytes, r.Action, r.Target)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, f := range r.Entries {
+		fmt.Fprintf(w, "\t%s\t%s\t%d bytes\n", f.Mode, f.Path, f.Size)
+	}
+	w.Flush()
+}
+
+// printPackages writes the packages and their versions as a table or json.
+func printPackages(packages []models.PackageInfo, err error) {
+	printReport(packages, err, func() {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, p := range packages {
+			fmt.Fprintln(w, p.Name)
+			for _, v := range p.Versions {
+				yanked := ""
+				if v.Yanked {
+					yanked = "yanked"
+				}
+				fmt.Fprintf(w, "\t%s\t%d bytes\t%s\t%s\n", v.Version, v.Size, v.ModTime.Format(time.DateTime), yanked)
+			}
+		}
+		w.Flush()
+	})
+}
+
+// printReport prints results as one json report, or through printText in
+// text mode, then exits with the mapped code when err is set.
+func printReport(results interface{}, err error, printText func()) {
+	if outputFormat() == formatJson {
+		printJson(newReport(results, err))
+		if err != nil {
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+	printText()
+	checkErr(err)
+}
+
+func printJson(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exitGeneric)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal/configs"
+	"PackageManager/internal/models"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var promoteFrom *string
+var promoteTo *string
+
+// promoteCmd represents the promote command
+var promoteCmd = &cobra.Command{
+	Use:   "promote <name>@<ver>... --from <repository> --to <repository>",
+	Short: "copy published versions between configured repositories",
+	Example: `  PackageManager promote packet-1@1.2 --from dev --to prod -f config.json
+  PackageManager promote packet-1@@stable --from staging --to prod -f config.json`,
+	Args:        cobra.MinimumNArgs(1),
+	Annotations: map[string]string{annotationRepositories: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		sshConfig, ok := viper.Get("ssh-config").(*configs.SSHConfig)
+		if !ok {
+			cobra.CheckErr("ssh-config is not a valid ssh config")
+		}
+		if *promoteFrom == *promoteTo {
+			checkErr(fmt.Errorf("%w: --from and --to name the same repository", models.ErrInvalidInput))
+		}
+		fromConfig, err := sshConfig.Repository(*promoteFrom)
+		checkErr(err)
+		toConfig, err := sshConfig.Repository(*promoteTo)
+		checkErr(err)
+		from, err := newRemoteClient(fromConfig)
+		checkErr(err)
+		defer from.Close()
+		to, err := newRemoteClient(toConfig)
+		checkErr(err)
+		defer to.Close()
+
+		refs := make([]models.Packages, 0, len(args))
+		for _, arg := range args {
+			refs = append(refs, models.ParsePackages(arg))
+		}
+		printResults(from.Promote(to, refs))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+
+	promoteFrom = promoteCmd.Flags().String("from", "", "repository to copy from, as named in the repositories of the config")
+	promoteTo = promoteCmd.Flags().String("to", "", "repository to copy to, as named in the repositories of the config")
+	promoteCmd.MarkFlagRequired("from")
+	promoteCmd.MarkFlagRequired("to")
+}
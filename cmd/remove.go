@@ -24,32 +24,46 @@ package cmd
 import (
 	"PackageManager/internal"
 	"PackageManager/internal/models"
+	"bufio"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var removeYes *bool
+var removeRetention *time.Duration
+
 // removeCmd represents the remove command
 var removeCmd = &cobra.Command{
-	Use:   "remove",
+	Use:   "remove [name@range...]",
 	Short: "remove exist package",
+	Example: `  PackageManager remove -u packages.json -f config.json
+  PackageManager remove 'packet-3@<=1.10' -f config.json
+  PackageManager remove packet-3 --yes --trash-retention 0 -f config.json`,
 	Run: func(cmd *cobra.Command, args []string) {
 		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
 		if !ok {
 			cobra.CheckErr("remote-client is not a valid remote client")
 		}
-		err := rClient.Remove(models.Delete(getUnpack()))
-		if err != nil {
-			cobra.CheckErr(err)
+		confirm := confirmRemoval
+		if *removeYes {
+			confirm = nil
 		}
+		printResults(rClient.Remove(models.Delete(getUnpack(cmd, args)), *removeRetention, confirm))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(removeCmd)
 
+	removeYes = removeCmd.Flags().BoolP("yes", "y", false, "remove several versions or a whole package without asking")
+	removeRetention = removeCmd.Flags().Duration("trash-retention", 30*24*time.Hour, "keep removed versions in the trash this long, 0 removes them for good")
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -61,7 +75,51 @@ func init() {
 	// removeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
-func getUnpack() models.Unpack {
+// confirmRemoval lists the versions about to be removed and asks on stdin
+// whether to go on. Anything but "y" or "yes" declines, end of input too.
+func confirmRemoval(planned []models.Result) bool {
+	fmt.Fprintf(os.Stderr, "about to remove %d versions:\n", len(planned))
+	for _, r := range planned {
+		fmt.Fprintf(os.Stderr, "\t%s@%s\t%s\n", r.Package, r.Version, r.Target)
+	}
+	fmt.Fprint(os.Stderr, "continue? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// getUnpack returns the packages given as name@range arguments merged with the
+// packages.json file. The file is read when no arguments are given or when it
+// is set explicitly; arguments replace file packages with the same name.
+func getUnpack(cmd *cobra.Command, args []string) models.Unpack {
+	unpack := models.Unpack{}
+	if len(args) == 0 || cmd.Flags().Changed("unpack") {
+		unpack = readUnpackFile()
+	}
+	for _, arg := range args {
+		ref := models.ParsePackages(arg)
+		if ref.Name == "" {
+			checkErr(fmt.Errorf("%w: package %q must be given as name[@range]", models.ErrInvalidInput, arg))
+		}
+		unpack.Packages = mergePackage(unpack.Packages, ref)
+	}
+	if len(unpack.Packages) == 0 {
+		checkErr(fmt.Errorf("%w: no packages given", models.ErrInvalidInput))
+	}
+	return unpack
+}
+
+func mergePackage(packages []models.Packages, ref models.Packages) []models.Packages {
+	for i, p := range packages {
+		if p.Name == ref.Name {
+			packages[i] = ref
+			return packages
+		}
+	}
+	return append(packages, ref)
+}
+
+func readUnpackFile() models.Unpack {
 	unpackFile, ok := viper.Get("unpack").(*string)
 	if !ok {
 		cobra.CheckErr("unpack is not a string")
@@ -0,0 +1,83 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var restoreList *bool
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <name>[@range]...",
+	Short: "bring removed versions back from the trash",
+	Example: `  PackageManager restore 'packet-1@=1.2' -f config.json
+  PackageManager restore --list -f config.json`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if *restoreList {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+		if !ok {
+			cobra.CheckErr("remote-client is not a valid remote client")
+		}
+		if *restoreList {
+			trashed, err := rClient.Trashed(args)
+			printReport(trashed, err, func() {
+				printTrashed(trashed)
+			})
+			return
+		}
+		refs := make([]models.Packages, 0, len(args))
+		for _, arg := range args {
+			refs = append(refs, models.ParsePackages(arg))
+		}
+		printResults(rClient.Restore(refs))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreList = restoreCmd.Flags().Bool("list", false, "list the versions in the trash of the given packages, or of all packages")
+}
+
+func printTrashed(trashed []models.Metadata) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, m := range trashed {
+		fmt.Fprintf(w, "%s@%s\t%d bytes\tremoved %s\texpires %s\n", m.Name, m.Version, m.Size,
+			m.Trashed.Format(time.DateTime), m.Expires.Format(time.DateTime))
+	}
+	w.Flush()
+}
@@ -27,9 +27,10 @@ import (
 	"PackageManager/internal/storage"
 	"context"
 	"fmt"
-	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -43,8 +44,24 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		validateOutputFormat()
+		if cmd.Annotations[annotationLocal] != "" {
+			initLocal()
+			return
+		}
+		initConfig(cmd)
+	},
 }
 
+// annotationLocal marks commands working on the output directory only,
+// they run without a storage configuration.
+const annotationLocal = "local"
+
+// annotationRepositories marks commands connecting to the repositories of
+// the config by name only, the default storage is not connected.
+const annotationRepositories = "repositories"
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -54,30 +71,69 @@ func Execute() {
 var fromEnv *bool
 var cfgFile *string
 
-func init() {
-	cobra.OnInitialize(initConfig)
+// workerNum is how many workers a command runs, set by mirror --workers
+var workerNum = 1
 
+func init() {
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	fromEnv = rootCmd.PersistentFlags().BoolP("env", "e", false, "read configs from environment")
 	cfgFile = rootCmd.PersistentFlags().StringP("cfg", "f", "", "configs file (default is empty)")
 	var pack = rootCmd.PersistentFlags().StringP("pack", "p", "packet.json", "input packet.json")
-	var unpack = rootCmd.PersistentFlags().StringP("unpack", "u", "packet.json", "input packages.json")
+	var unpack = rootCmd.PersistentFlags().StringP("unpack", "u", "packages.json", "input packages.json")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	storage_path := rootCmd.PersistentFlags().StringP("storage_path", "s", ".", "path in remote storage server for saving files")
 	output := rootCmd.PersistentFlags().StringP("output", "o", ".", "path for save fetching packages")
+	outputFormat := rootCmd.PersistentFlags().String("output-format", formatText, "format of command results: text or json")
+	cacheDir := rootCmd.PersistentFlags().String("cache-dir", defaultCacheDir(), "directory keeping fetched archives, delta versions are applied to the cached base (empty disables caching)")
+	dryRun := rootCmd.PersistentFlags().Bool("dry-run", false, "show what would be packed, fetched or removed without changing anything")
+	spoolDir := rootCmd.PersistentFlags().String("spool-dir", "", "write archives to a temporary file in this directory before uploading instead of streaming them")
+	lockTTL := rootCmd.PersistentFlags().Duration("lock-ttl", 15*time.Minute, "how long locks taken on the storage are honored before others may break them")
+	lockWait := rootCmd.PersistentFlags().Duration("lock-wait", time.Minute, "how long to wait for a lock held by another client")
 
 	viper.Set("pack", pack)
 	viper.Set("unpack", unpack)
 	viper.Set("storage_path", storage_path)
 	viper.Set("output", output)
+	viper.Set("output-format", outputFormat)
+	viper.Set("spool-dir", spoolDir)
+	viper.Set("cache-dir", cacheDir)
+	viper.Set("dry-run", dryRun)
+	viper.Set("lock-ttl", lockTTL)
+	viper.Set("lock-wait", lockWait)
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "PackageManager")
+}
+
+// clientContext adds the client options set by flags to ctx.
+func clientContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, "workerNum", workerNum)
+	ctx = context.WithValue(ctx, "spool-dir", *viper.Get("spool-dir").(*string))
+	ctx = context.WithValue(ctx, "cache-dir", *viper.Get("cache-dir").(*string))
+	ctx = context.WithValue(ctx, "lock-ttl", *viper.Get("lock-ttl").(*time.Duration))
+	ctx = context.WithValue(ctx, "lock-wait", *viper.Get("lock-wait").(*time.Duration))
+	return context.WithValue(ctx, "dry-run", *viper.Get("dry-run").(*bool))
 }
 
-// initConfig reads in configs file and ENV variables if set.
-func initConfig() {
+// initLocal sets up a client without storage for local commands.
+func initLocal() {
+	rClient, err := internal.NewRemoteClient(clientContext(context.Background()), nil)
+	checkErr(err)
+	viper.Set("remote-client", rClient)
+}
+
+// initConfig reads in configs file and ENV variables if set, and connects
+// to the default storage unless cmd only uses named repositories.
+func initConfig(cmd *cobra.Command) {
 	sshConfig := configs.NewSSHConfig()
 	if *cfgFile != "" && *fromEnv {
 		cobra.CheckErr(tracerr.New("cant use configs from environment and cfg file together, use onl one flag"))
@@ -109,20 +165,23 @@ func initConfig() {
 		cobra.CheckErr(err)
 	}
 	viper.Set("ssh-config", sshConfig)
+	if cmd.Annotations[annotationRepositories] != "" {
+		return
+	}
 
-	ctx := context.WithValue(context.Background(), "ssh-config", sshConfig)
+	rClient, err := newRemoteClient(sshConfig)
+	checkErr(err)
 
-	ctx = context.WithValue(ctx, "workerNum", 1)
+	viper.Set("remote-client", rClient)
+}
 
-	sshClient, err := storage.NewSshClient(ctx)
-	if err != nil {
-		log.Println(tracerr.Sprint(err))
-	}
+// newRemoteClient connects a client to the storage of sshConfig.
+func newRemoteClient(sshConfig *configs.SSHConfig) (*internal.PackageManager, error) {
+	ctx := clientContext(context.WithValue(context.Background(), "ssh-config", sshConfig))
 
-	rClient, err := internal.NewRemoteClient(ctx, sshClient)
+	sshClient, err := storage.NewSshClient(ctx)
 	if err != nil {
-		log.Println(tracerr.Sprint(err))
+		return nil, tracerr.Wrap(err)
 	}
-
-	viper.Set("remote-client", rClient)
+	return internal.NewRemoteClient(ctx, sshClient)
 }
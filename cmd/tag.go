@@ -0,0 +1,99 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag <name>@<ver> <tag>... | tag <name>",
+	Short: "point tags of a package at a version, or list its tags",
+	Example: `  PackageManager tag packet-1@2.10 stable latest -f config.json
+  PackageManager tag packet-1 -f config.json
+  PackageManager fetch 'packet-1@@stable' -f config.json`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+		if !ok {
+			cobra.CheckErr("remote-client is not a valid remote client")
+		}
+		ref := models.ParsePackages(args[0])
+		if len(args) == 1 {
+			if ref.Ver != "" {
+				checkErr(fmt.Errorf("%w: no tags given for %s", models.ErrInvalidInput, args[0]))
+			}
+			tags, err := rClient.Tags(ref.Name)
+			printReport(tags, err, func() {
+				printTags(tags)
+			})
+			return
+		}
+		results, err := rClient.Tag(ref.Name, ref.Ver, args[1:])
+		printTagResults(results, err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+}
+
+// printTagResults writes one line per tag changed by tag or untag.
+func printTagResults(results []models.Result, err error) {
+	printReport(results, err, func() {
+		for _, r := range results {
+			line := fmt.Sprintf("tag: %s already points at %s@%s", r.Target, r.Package, r.Version)
+			switch r.Action {
+			case "tag":
+				line = fmt.Sprintf("tag: %s points at %s@%s", r.Target, r.Package, r.Version)
+			case "untag":
+				line = fmt.Sprintf("tag: %s of %s@%s removed", r.Target, r.Package, r.Version)
+			}
+			if r.Status == models.StatusDryRun {
+				line += " (dry run)"
+			}
+			fmt.Println(line)
+		}
+	})
+}
+
+func printTags(tags models.Tags) {
+	names := make([]string, 0, len(tags))
+	for tag := range tags {
+		names = append(names, tag)
+	}
+	slices.Sort(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, tag := range names {
+		fmt.Fprintf(w, "%s\t%s\n", tag, tags[tag])
+	}
+	w.Flush()
+}
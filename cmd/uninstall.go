@@ -0,0 +1,63 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var uninstallForce *bool
+
+// uninstallCmd represents the uninstall command
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall <name>[@range]...",
+	Short: "remove the files of installed packages from the output directory",
+	Example: `  PackageManager uninstall packet-1 -o output
+  PackageManager uninstall 'packet-1@<2.0' --force -o output`,
+	Args:        cobra.MinimumNArgs(1),
+	Annotations: map[string]string{annotationLocal: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+		if !ok {
+			cobra.CheckErr("remote-client is not a valid remote client")
+		}
+		output, ok := viper.Get("output").(*string)
+		if !ok {
+			cobra.CheckErr("output is not a string")
+		}
+		refs := make([]models.Packages, 0, len(args))
+		for _, arg := range args {
+			refs = append(refs, models.ParsePackages(arg))
+		}
+		printResults(rClient.Uninstall(*output, refs, *uninstallForce))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+
+	uninstallForce = uninstallCmd.Flags().Bool("force", false, "remove locally modified files too")
+}
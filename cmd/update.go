@@ -30,9 +30,11 @@ import (
 	"github.com/spf13/viper"
 )
 
+var force *bool
+
 // updateCmd represents the update command
 var updateCmd = &cobra.Command{
-	Use:   "update",
+	Use:   "update [name@version...]",
 	Short: "create a new or update existing package",
 	Run: func(cmd *cobra.Command, args []string) {
 		rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
@@ -40,16 +42,16 @@ var updateCmd = &cobra.Command{
 			cobra.CheckErr("remote-client is not a valid remote client")
 		}
 		log.Println("updating packages...")
-		err := rClient.Update(models.Update(getPack()))
-		if err != nil {
-			cobra.CheckErr(err)
-		}
+		printResults(rClient.Update(models.Update(getPack(cmd, args)), *force))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
 
+	force = updateCmd.Flags().Bool("force", false, "overwrite already published versions")
+	addTargetFlags(updateCmd)
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
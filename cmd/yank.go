@@ -0,0 +1,57 @@
+/*
+Copyright © november 2025 vetab60 <al9xgr99n@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"PackageManager/internal"
+	"PackageManager/internal/models"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// yankCmd represents the yank command
+var yankCmd = &cobra.Command{
+	Use:     "yank <name>@<ver>...",
+	Short:   "withdraw published versions without removing them",
+	Example: `  PackageManager yank packet-1@1.2 -f config.json`,
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runYank(args, true)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(yankCmd)
+}
+
+func runYank(args []string, yanked bool) {
+	rClient, ok := viper.Get("remote-client").(*internal.PackageManager)
+	if !ok {
+		cobra.CheckErr("remote-client is not a valid remote client")
+	}
+	refs := make([]models.Packages, 0, len(args))
+	for _, arg := range args {
+		refs = append(refs, models.ParsePackages(arg))
+	}
+	printResults(rClient.Yank(refs, yanked))
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/mholt/archives"
+	"github.com/ztrue/tracerr"
+)
+
+// entryHeader describes a file, symlink or directory written to a package.
+// Owners are not recorded, tar entries always belong to uid and gid 0.
+type entryHeader struct {
+	Name       string
+	Mode       fs.FileMode
+	ModTime    time.Time
+	Size       int64
+	LinkTarget string
+}
+
+// archiveWriter writes package entries in one of models.ArchiveFormats.
+type archiveWriter interface {
+	// CreateEntry starts a new entry. The content of files is written to the
+	// returned writer, the target of symlinks as well; the tar formats keep
+	// the target in the header and discard it.
+	CreateEntry(header entryHeader) (io.Writer, error)
+	Close() error
+}
+
+// _deflate_level is used by zip and tar.gz. Compression levels are pinned
+// rather than left to library defaults so packages stay byte-identical.
+const _deflate_level = flate.DefaultCompression
+
+// tarCompressor returns the compression of a tar based format. Reproducible
+// packages are zstd compressed by a single encoder goroutine.
+func tarCompressor(format string, reproducible bool) (archives.Compressor, bool) {
+	switch format {
+	case models.FormatTarGz:
+		return archives.Gz{CompressionLevel: _deflate_level}, true
+	case models.FormatTarZst:
+		options := []zstd.EOption{zstd.WithEncoderLevel(zstd.SpeedDefault)}
+		if reproducible {
+			options = append(options, zstd.WithEncoderConcurrency(1))
+		}
+		return archives.Zstd{EncoderOptions: options}, true
+	case models.FormatTarXz:
+		return archives.Xz{}, true
+	}
+	return nil, false
+}
+
+func newArchiveWriter(w io.Writer, format string, reproducible bool) (archiveWriter, error) {
+	if format == models.FormatZip {
+		writer := zip.NewWriter(w)
+		writer.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, _deflate_level)
+		})
+		return &zipArchiveWriter{writer: writer}, nil
+	}
+	compressor, ok := tarCompressor(format, reproducible)
+	if !ok {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: unknown archive format %q", models.ErrInvalidInput, format))
+	}
+	compressed, err := compressor.OpenWriter(w)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return &tarArchiveWriter{writer: tar.NewWriter(compressed), compressed: compressed}, nil
+}
+
+type zipArchiveWriter struct {
+	writer *zip.Writer
+}
+
+func (z *zipArchiveWriter) CreateEntry(header entryHeader) (io.Writer, error) {
+	zipHeader := &zip.FileHeader{
+		Name:               header.Name,
+		Method:             zip.Deflate,
+		Modified:           header.ModTime,
+		UncompressedSize64: uint64(header.Size),
+	}
+	zipHeader.SetMode(header.Mode)
+	if header.Mode.IsDir() {
+		zipHeader.Name += "/"
+	}
+	if header.Mode.IsDir() || header.Mode&fs.ModeSymlink != 0 {
+		zipHeader.Method = zip.Store
+	}
+	return z.writer.CreateHeader(zipHeader)
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.writer.Close()
+}
+
+type tarArchiveWriter struct {
+	writer     *tar.Writer
+	compressed io.WriteCloser
+}
+
+func (t *tarArchiveWriter) CreateEntry(header entryHeader) (io.Writer, error) {
+	tarHeader := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     header.Name,
+		Mode:     tarMode(header.Mode),
+		ModTime:  header.ModTime,
+		Size:     header.Size,
+		Format:   tar.FormatPAX,
+	}
+	switch {
+	case header.Mode.IsDir():
+		tarHeader.Typeflag = tar.TypeDir
+		tarHeader.Name += "/"
+		tarHeader.Size = 0
+	case header.Mode&fs.ModeSymlink != 0:
+		tarHeader.Typeflag = tar.TypeSymlink
+		tarHeader.Linkname = header.LinkTarget
+		tarHeader.Size = 0
+	}
+	if err := t.writer.WriteHeader(tarHeader); err != nil {
+		return nil, err
+	}
+	if tarHeader.Typeflag != tar.TypeReg {
+		return io.Discard, nil
+	}
+	return t.writer, nil
+}
+
+func (t *tarArchiveWriter) Close() error {
+	if err := t.writer.Close(); err != nil {
+		t.compressed.Close()
+		return err
+	}
+	return t.compressed.Close()
+}
+
+// tarMode converts the permission and special bits of mode to the tar header
+// encoding.
+func tarMode(mode fs.FileMode) int64 {
+	ret := int64(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		ret |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		ret |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		ret |= 01000
+	}
+	return ret
+}
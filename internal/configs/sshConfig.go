@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"PackageManager/internal/models"
+	"fmt"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -9,6 +11,9 @@ import (
 
 type SSHConfig struct {
 	SSHConfig_ `mapstructure:"ssh"`
+	// Repositories are further storages by name, e.g. "dev" and "prod".
+	// Each one takes the fields it leaves empty from the ssh section.
+	Repositories map[string]SSHConfig_ `mapstructure:"repositories"`
 }
 
 type SSHConfig_ struct {
@@ -59,3 +64,38 @@ func (s *SSHConfig) Validate() error {
 
 	return nil
 }
+
+// Repository returns the config of the repository name, the ssh section
+// with the fields the repository sets replaced.
+func (s *SSHConfig) Repository(name string) (*SSHConfig, error) {
+	repo, ok := s.Repositories[name]
+	if !ok {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: repository %q is not configured", models.ErrInvalidInput, name))
+	}
+	cfg := &SSHConfig{SSHConfig_: s.SSHConfig_}
+	if repo.Username != "" {
+		cfg.Username = repo.Username
+	}
+	if repo.Password != "" {
+		cfg.Password = repo.Password
+	}
+	if repo.Host != "" {
+		cfg.Host = repo.Host
+	}
+	if repo.Port != 0 {
+		cfg.Port = repo.Port
+	}
+	if repo.Timeout != 0 {
+		cfg.Timeout = repo.Timeout
+	}
+	if repo.PrivateKeyFile != "" {
+		cfg.PrivateKeyFile = repo.PrivateKeyFile
+	}
+	if len(repo.SshKeyExchanges) > 0 {
+		cfg.SshKeyExchanges = repo.SshKeyExchanges
+	}
+	if repo.SshStoragePath != "" {
+		cfg.SshStoragePath = repo.SshStoragePath
+	}
+	return cfg, nil
+}
@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"PackageManager/internal/models"
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -56,4 +58,32 @@ func TestNewSSHConfig(t *testing.T) {
 			t.Fatal("sshCfg_2 should be equal to sshCfg")
 		}
 	})
+
+	t.Run("repository config", func(t *testing.T) {
+		sshCfg := NewSSHConfig()
+		sshCfg.Username = "username"
+		sshCfg.Host = "host"
+		sshCfg.Port = 22
+		sshCfg.SshStoragePath = "remote"
+		sshCfg.Repositories = map[string]SSHConfig_{
+			"dev":  {SshStoragePath: "remote/dev"},
+			"prod": {Host: "prod", SshStoragePath: "/srv/packages"},
+		}
+		dev, err := sshCfg.Repository("dev")
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		assert.Equal(t, "host", dev.Host)
+		assert.Equal(t, "remote/dev", dev.SshStoragePath)
+		prod, err := sshCfg.Repository("prod")
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		assert.Equal(t, "prod", prod.Host)
+		assert.Equal(t, 22, prod.Port)
+		assert.Equal(t, "remote", sshCfg.SshStoragePath)
+		if _, err = sshCfg.Repository("staging"); !errors.Is(err, models.ErrInvalidInput) {
+			t.Fatalf("want ErrInvalidInput, got %v", err)
+		}
+	})
 }
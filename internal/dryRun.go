@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// plannedCreate describes the archive create would build for p from files
+// without reading their contents. With a delta base, files unchanged since
+// the base are left out like they would be from the archive.
+func plannedCreate(action string, p models.Packets, files []archiveEntry, base map[string]models.ManifestFile,
+	normalize bool, started time.Time) (models.Result, error) {
+	result := models.NewResult(action, p.Name, p.Ver, started)
+	result.Status = models.StatusDryRun
+	result.Entries = make([]models.ManifestFile, 0, len(files))
+	for _, match := range files {
+		if base != nil {
+			_, unchanged, err := unchangedEntry(base, match.Path, match.Name, normalize)
+			if err != nil {
+				return result, tracerr.Wrap(err)
+			}
+			if unchanged {
+				continue
+			}
+		}
+		info, err := os.Lstat(match.Path)
+		if err != nil {
+			return result, tracerr.Wrap(err)
+		}
+		mode := info.Mode()
+		if normalize {
+			mode = normalizeMode(mode)
+		}
+		file := models.ManifestFile{Path: match.Name, Mode: mode.String()}
+		if !info.IsDir() {
+			file.Size = info.Size()
+			result.Files++
+			result.Bytes += file.Size
+		}
+		result.Entries = append(result.Entries, file)
+	}
+	return result, nil
+}
+
+// plannedFetch describes the files fetch would install for name@ver. The
+// archive is listed without being extracted or added to the cache; the
+// manifest of a delta version lists the files of its base too.
+func (u *PackageManager) plannedFetch(name, ver, archive string,
+	f func(versionStatement string) (models.IArchiveStream, error), started time.Time) (models.Result, error) {
+	result := models.NewResult("fetch", name, ver, started)
+	result.Status = models.StatusDryRun
+	stream, err := f(fmt.Sprintf("%s/%s", name, archive))
+	if err != nil {
+		return result, tracerr.Wrap(err)
+	}
+	defer stream.Close()
+	listed, manifest, err := u.listArchive(stream)
+	if err != nil {
+		return result, tracerr.Wrap(err)
+	}
+	if manifest != nil {
+		result.Entries = manifest.Files
+	} else {
+		result.Entries = make([]models.ManifestFile, 0, len(listed))
+		for _, file := range listed {
+			result.Entries = append(result.Entries, models.ManifestFile{Path: file.Path, Mode: file.Mode, Size: file.Size})
+		}
+	}
+	for _, file := range result.Entries {
+		if !strings.HasPrefix(file.Mode, "d") {
+			result.Files++
+			result.Bytes += file.Size
+		}
+	}
+	if meta, err := u.readMetadata(name, ver); err == nil {
+		result.Digest = meta.Digest
+	}
+	return result, nil
+}
+
+// plannedRemoval describes the files of installed that removing it from the
+// output directory would delete, unless another package owns them.
+func plannedRemoval(action string, installed models.InstalledPackage, started time.Time) models.Result {
+	result := models.NewResult(action, installed.Name, installed.Version, started)
+	result.Status = models.StatusDryRun
+	result.Digest = installed.Digest
+	result.Target = installed.Dir
+	result.Entries = installed.Files
+	for _, file := range installed.Files {
+		if !strings.HasPrefix(file.Mode, "d") {
+			result.Files++
+			result.Bytes += file.Size
+		}
+	}
+	return result
+}
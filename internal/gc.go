@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// GC removes the versions the retention rules of policy do not keep, nor
+// locked references. Versions locked by another client on the storage are
+// left to the next run. Nothing is removed in a dry run, the results report
+// what would be reclaimed.
+func (u *PackageManager) GC(policy models.GCPolicy, locked []models.LockedPackage) ([]models.Result, error) {
+	return u.gc(policy, locked, time.Now(), u.client.Remove)
+}
+
+func (u *PackageManager) gc(policy models.GCPolicy, locked []models.LockedPackage, now time.Time,
+	f func(rm string) error) ([]models.Result, error) {
+	u.resetIndex()
+	results := make([]models.Result, 0)
+	packages, err := u.getPackages()
+	if err != nil {
+		return results, tracerr.Wrap(err)
+	}
+	for _, p := range packages {
+		name := p.Name()
+		rule, err := policy.Rule(name)
+		if err != nil {
+			return results, tracerr.Wrap(err)
+		}
+		if rule == nil {
+			continue
+		}
+		info, err := u.packageInfo(name, "", utils.ALL)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("gc", name, "", err))
+		}
+		tags, err := u.readTags(name)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("gc", name, "", err))
+		}
+		kept, err := u.retained(info, *rule, locked, tags, now)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("gc", name, "", err))
+		}
+
+		for _, version := range info.Versions {
+			if kept[version.Version] {
+				continue
+			}
+			started := time.Now()
+			result := models.NewResult("remove", name, version.Version, started)
+			result.Bytes = version.Size
+			if u.dryRun {
+				result.Status = models.StatusDryRun
+				result.Target = path.Join(name, version.File)
+				results = append(results, result)
+				continue
+			}
+			log.Printf("package: %s@%s is not retained, removing it", name, version.Version)
+			removed, err := u.collect(name, version, f)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("gc", name, version.Version, err))
+			}
+			if !removed {
+				log.Printf("package: %s@%s is locked by another client, leaving it to the next gc", name, version.Version)
+				continue
+			}
+			result.DurationMs = time.Since(started).Milliseconds()
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// collect removes version of name holding its lock, like remove does. It
+// reports false without removing anything when another client holds the
+// lock.
+func (u *PackageManager) collect(name string, version models.VersionInfo, f func(rm string) error) (bool, error) {
+	unlock, err := u.lock(models.VersionLock(name, version.Version))
+	if errors.Is(err, models.ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	defer unlock()
+	if err = f(path.Join(name, version.File)); err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	return true, tracerr.Wrap(u.updateIndex(func(index *models.Index) {
+		index.Delete(name, version.Version)
+	}))
+}
+
+// retained returns the versions of info that rule or locked keep, and the
+// ones tags point at, with the delta bases they need.
+func (u *PackageManager) retained(info models.PackageInfo, rule models.RetentionRule, locked []models.LockedPackage,
+	tags models.Tags, now time.Time) (map[string]bool, error) {
+	kept := make(map[string]bool)
+	for _, ver := range tags {
+		kept[ver] = true
+	}
+	for i, version := range info.Versions {
+		keep := i >= len(info.Versions)-rule.KeepLatest ||
+			(rule.KeepDays > 0 && now.Sub(version.ModTime) < time.Duration(rule.KeepDays)*24*time.Hour)
+		for _, constraint := range rule.Keep {
+			op, needVer := utils.ParseVersionRef(constraint)
+			ok, err := utils.CompareVersions(version.Version, needVer, op)
+			if err != nil {
+				return nil, tracerr.Wrap(fmt.Errorf("%w: keep %q: %w", models.ErrInvalidInput, constraint, err))
+			}
+			keep = keep || ok
+		}
+		for _, ref := range locked {
+			ok, err := lockedVersion(ref, info.Name, version.Version)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			keep = keep || ok
+		}
+		if keep {
+			kept[version.Version] = true
+		}
+	}
+
+	// a delta is useless without its base, keep the whole chain
+	pending := make([]string, 0, len(kept))
+	for ver := range kept {
+		pending = append(pending, ver)
+	}
+	for len(pending) > 0 {
+		ver := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		meta, err := u.readMetadata(info.Name, ver)
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if meta.Base != "" && !kept[meta.Base] {
+			kept[meta.Base] = true
+			pending = append(pending, meta.Base)
+		}
+	}
+	return kept, nil
+}
+
+// lockedVersion reports whether ref locks name@ver. References to a tag lock
+// nothing, tagged versions are kept anyway.
+func lockedVersion(ref models.LockedPackage, name, ver string) (bool, error) {
+	if _, isTag := models.TagRef(ref.Ver); ref.Name != name || (ref.Version == "" && isTag) {
+		return false, nil
+	}
+	if ref.Version != "" {
+		return ref.Version == ver, nil
+	}
+	op, needVer := utils.ParseVersionRef(ref.Ver)
+	ok, err := utils.CompareVersions(ver, needVer, op)
+	if err != nil {
+		return false, tracerr.Wrap(fmt.Errorf("%w: lockfile %s@%s: %w", models.ErrInvalidInput, ref.Name, ref.Ver, err))
+	}
+	return ok, nil
+}
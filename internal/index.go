@@ -0,0 +1,230 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// loadIndex returns the index of the storage, nil for storages without one.
+// It is read once per operation, changes made through u keep it current.
+func (u *PackageManager) loadIndex() (*models.Index, error) {
+	if u.index != nil || u.noIndex {
+		return u.index, nil
+	}
+	index, err := u.readIndex()
+	if errors.Is(err, models.ErrNotFound) {
+		u.noIndex = true
+		return nil, nil
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	u.index = index
+	return index, nil
+}
+
+// resetIndex forgets the loaded index, every operation starts from the
+// index as stored.
+func (u *PackageManager) resetIndex() {
+	u.index, u.noIndex = nil, false
+}
+
+func (u *PackageManager) readIndex() (*models.Index, error) {
+	bs, err := u.client.ReadIndex()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	index := &models.Index{}
+	if err = json.Unmarshal(bs, index); err != nil {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %s: %w, run reindex", models.ErrIntegrity, models.IndexName, err))
+	}
+	return index, nil
+}
+
+// updateIndex applies change to the index as currently stored and replaces
+// it as a whole, readers see either the old or the new index. The index lock
+// is held meanwhile so concurrent changes are not lost. A storage without
+// index gets one built from its directory tree first.
+func (u *PackageManager) updateIndex(change func(index *models.Index)) error {
+	unlock, err := u.lock(models.IndexLock)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer unlock()
+	index, err := u.readIndex()
+	if errors.Is(err, models.ErrNotFound) {
+		log.Printf("storage has no %s yet, building it", models.IndexName)
+		index, err = u.buildIndex()
+	}
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	change(index)
+	return tracerr.Wrap(u.writeIndex(index))
+}
+
+func (u *PackageManager) writeIndex(index *models.Index) error {
+	index.Updated = time.Now().UTC()
+	sort.Slice(index.Packages, func(i, j int) bool { return index.Packages[i].Name < index.Packages[j].Name })
+	for _, p := range index.Packages {
+		sortIndexVersions(p.Versions)
+	}
+	bs, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err = u.client.WriteIndex(bs); err != nil {
+		return tracerr.Wrap(err)
+	}
+	u.index, u.noIndex = index, false
+	return nil
+}
+
+// buildIndex reads the index from the directory tree and the metadata of
+// every version. Versions published without metadata are indexed with the
+// size and time of their archive.
+func (u *PackageManager) buildIndex() (*models.Index, error) {
+	index := &models.Index{Packages: []models.IndexPackage{}}
+	packages, err := u.client.GetPackages()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	for _, p := range packages {
+		versions, err := u.client.GetVersions(p.Name())
+		if err != nil {
+			return nil, tracerr.Wrap(models.NewPackageError("reindex", p.Name(), "", err))
+		}
+		for _, version := range versions {
+			ver, format, isArchive := models.SplitArchiveName(version.Name())
+			if !isArchive {
+				log.Printf("package: %s skipping unknown archive %s", p.Name(), version.Name())
+				continue
+			}
+			meta, err := u.readMetadata(p.Name(), ver)
+			if errors.Is(err, models.ErrNotFound) {
+				meta = models.Metadata{Name: p.Name(), Version: ver, Format: format, Size: version.Size(), Uploaded: version.ModTime()}
+			} else if err != nil {
+				return nil, tracerr.Wrap(models.NewPackageError("reindex", p.Name(), ver, err))
+			}
+			index.Put(p.Name(), models.IndexVersionOf(meta, version.Name()))
+		}
+	}
+	return index, nil
+}
+
+// Reindex rebuilds the index from the directory tree, for storages written
+// before it was kept or changed by hand. A dry run returns it unwritten.
+func (u *PackageManager) Reindex() (*models.Index, error) {
+	if !u.dryRun {
+		unlock, err := u.lock(models.IndexLock)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		defer unlock()
+	}
+	index, err := u.buildIndex()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if u.dryRun {
+		return index, nil
+	}
+	return index, tracerr.Wrap(u.writeIndex(index))
+}
+
+// getVersions lists the archives of name from the index, or from the
+// package directory if the index does not know it.
+func (u *PackageManager) getVersions(name string) ([]os.FileInfo, error) {
+	index, err := u.loadIndex()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if index != nil {
+		if p := index.Package(name); p != nil {
+			versions := make([]os.FileInfo, 0, len(p.Versions))
+			for _, version := range p.Versions {
+				versions = append(versions, indexedFile{name: version.File, size: version.Size, modTime: version.Uploaded})
+			}
+			return versions, nil
+		}
+	}
+	return u.client.GetVersions(name)
+}
+
+// indexedVersion returns the index entry of name@ver, nil if the storage
+// has no index or it does not know the version.
+func (u *PackageManager) indexedVersion(name, ver string) (*models.IndexVersion, error) {
+	index, err := u.loadIndex()
+	if err != nil || index == nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if p := index.Package(name); p != nil {
+		for i := range p.Versions {
+			if p.Versions[i].Version == ver {
+				return &p.Versions[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// getPackages lists the packages from the index, or from the storage root
+// for storages without one.
+func (u *PackageManager) getPackages() ([]os.FileInfo, error) {
+	index, err := u.loadIndex()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if index == nil {
+		return u.client.GetPackages()
+	}
+	packages := make([]os.FileInfo, 0, len(index.Packages))
+	for _, p := range index.Packages {
+		packages = append(packages, indexedFile{name: p.Name, dir: true, modTime: index.Updated})
+	}
+	return packages, nil
+}
+
+func sortIndexVersions(versions []models.IndexVersion) {
+	names := make([]string, 0, len(versions))
+	byVersion := make(map[string]models.IndexVersion, len(versions))
+	for _, v := range versions {
+		names = append(names, v.Version)
+		byVersion[v.Version] = v
+	}
+	utils.SortVersions(names)
+	for i, name := range names {
+		versions[i] = byVersion[name]
+	}
+}
+
+// indexedFile presents an index entry like the directory listing would.
+type indexedFile struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (f indexedFile) Name() string       { return f.name }
+func (f indexedFile) Size() int64        { return f.size }
+func (f indexedFile) ModTime() time.Time { return f.modTime }
+func (f indexedFile) IsDir() bool        { return f.dir }
+func (f indexedFile) Sys() any           { return nil }
+
+func (f indexedFile) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
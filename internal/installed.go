@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// Installed returns the packages recorded in the install database of output.
+func (u *PackageManager) Installed(output string) ([]models.InstalledPackage, error) {
+	db, err := loadInstallDB(output)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return db.Packages, nil
+}
+
+// Verify checks the files of the packages installed into output against the
+// digests recorded when they were installed, all packages if names is empty.
+// An ErrIntegrity error is returned along with the checks when any file was
+// changed or removed.
+func (u *PackageManager) Verify(output string, names []string) ([]models.InstallCheck, error) {
+	checks := make([]models.InstallCheck, 0)
+	db, err := loadInstallDB(output)
+	if err != nil {
+		return checks, tracerr.Wrap(err)
+	}
+	for _, name := range names {
+		if len(db.Installs(name)) == 0 {
+			return checks, tracerr.Wrap(models.NewPackageError("verify", name, "", fmt.Errorf("%w: not installed", models.ErrNotFound)))
+		}
+	}
+
+	failed := make([]string, 0)
+	for i := range db.Packages {
+		installed := &db.Packages[i]
+		if len(names) > 0 && !slices.Contains(names, installed.Name) {
+			continue
+		}
+		modified, missing, err := checkInstalled(output, installed)
+		if err != nil {
+			return checks, tracerr.Wrap(models.NewPackageError("verify", installed.Name, installed.Version, err))
+		}
+		check := models.InstallCheck{
+			Name:     installed.Name,
+			Version:  installed.Version,
+			Dir:      installed.Dir,
+			Status:   models.StatusOk,
+			Modified: modified,
+			Missing:  missing,
+		}
+		if len(modified) > 0 || len(missing) > 0 {
+			check.Status = models.StatusModified
+			failed = append(failed, installed.Name+"@"+installed.Version)
+		}
+		checks = append(checks, check)
+	}
+	if len(failed) > 0 {
+		return checks, tracerr.Wrap(fmt.Errorf("%w: installed files were changed: %s", models.ErrIntegrity, strings.Join(failed, ", ")))
+	}
+	return checks, nil
+}
+
+// Uninstall removes the files of the installed versions of refs from output
+// and forgets them, a version without operator names that version. Files
+// shared with another installed package are kept. Locally modified files are
+// not removed unless force is set.
+func (u *PackageManager) Uninstall(output string, refs []models.Packages, force bool) ([]models.Result, error) {
+	results := make([]models.Result, 0, len(refs))
+	db, err := loadInstallDB(output)
+	if err != nil {
+		return results, tracerr.Wrap(err)
+	}
+
+	// check every version about to be removed before touching any of them
+	remove := make([]models.InstalledPackage, 0, len(refs))
+	conflicts := make([]string, 0)
+	for _, ref := range refs {
+		op, needVer := utils.ParseVersionRef(ref.Ver)
+		matched := 0
+		for _, installed := range db.Installs(ref.Name) {
+			ok, err := utils.CompareVersions(installed.Version, needVer, op)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("uninstall", ref.Name, ref.Ver, fmt.Errorf("%w: %w", models.ErrInvalidInput, err)))
+			}
+			if !ok {
+				continue
+			}
+			matched++
+			modified, _, err := checkInstalled(output, &installed)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("uninstall", installed.Name, installed.Version, err))
+			}
+			conflicts = append(conflicts, modified...)
+			remove = append(remove, installed)
+		}
+		if matched == 0 {
+			return results, tracerr.Wrap(models.NewPackageError("uninstall", ref.Name, ref.Ver, fmt.Errorf("%w: not installed", models.ErrNotFound)))
+		}
+	}
+	if len(conflicts) > 0 {
+		if !force {
+			return results, tracerr.Wrap(fmt.Errorf("%w: locally modified files would be removed, use force: %s",
+				models.ErrConflict, strings.Join(conflicts, ", ")))
+		}
+		log.Printf("removing locally modified files: %s", strings.Join(conflicts, ", "))
+	}
+	if u.dryRun {
+		for _, installed := range remove {
+			results = append(results, plannedRemoval("uninstall", installed, time.Now()))
+		}
+		return results, nil
+	}
+
+	for _, installed := range remove {
+		started := time.Now()
+		log.Println(fmt.Sprintf("uninstalling %s@%s from %s...", installed.Name, installed.Version, output))
+		result, err := u.uninstall(output, db, installed, started)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("uninstall", installed.Name, installed.Version, err))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// uninstall forgets installed and removes the files no other package owns.
+func (u *PackageManager) uninstall(output string, db *models.InstallDB, installed models.InstalledPackage,
+	started time.Time) (models.Result, error) {
+	db.Delete(installed.Name, installed.Dir)
+	if err := removeOwned(output, db, installed); err != nil {
+		return models.Result{}, tracerr.Wrap(err)
+	}
+	if err := saveInstallDB(output, db); err != nil {
+		return models.Result{}, tracerr.Wrap(err)
+	}
+	result := models.NewResult("uninstall", installed.Name, installed.Version, started)
+	result.Digest = installed.Digest
+	for _, file := range installed.Files {
+		if !strings.HasPrefix(file.Mode, "d") {
+			result.Files++
+			result.Bytes += file.Size
+		}
+	}
+	return result, nil
+}
+
+// withoutFiles returns installed without the files at paths.
+func withoutFiles(installed models.InstalledPackage, paths []string) models.InstalledPackage {
+	if len(paths) == 0 {
+		return installed
+	}
+	files := make([]models.ManifestFile, 0, len(installed.Files))
+	for _, file := range installed.Files {
+		if !slices.Contains(paths, file.Path) {
+			files = append(files, file)
+		}
+	}
+	installed.Files = files
+	return installed
+}
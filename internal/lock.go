@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/user"
+	"slices"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// _default_lock_ttl is how long a lock is honored when no ttl is set, a
+// holder that crashed blocks others at most that long.
+const _default_lock_ttl = 15 * time.Minute
+
+// _lock_retry is the interval lock is retried at while waiting.
+const _lock_retry = time.Second
+
+// lock acquires the advisory lock name on the storage, waiting up to
+// u.lockWait for its holder to release it. A lock that expired is broken.
+// The returned func releases it.
+func (u *PackageManager) lock(name string) (func(), error) {
+	file := models.LockFile(name)
+	deadline := time.Now().Add(u.lockWait)
+	waiting := false
+	for {
+		lock := newLock(name, u.lockTTL)
+		bs, err := json.MarshalIndent(lock, "", "  ")
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		err = u.client.CreateLock(file, bs)
+		if err == nil {
+			return func() { u.unlock(lock) }, nil
+		}
+		if !errors.Is(err, models.ErrConflict) {
+			return nil, tracerr.Wrap(err)
+		}
+
+		held, err := u.readLock(file)
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			// released meanwhile
+			continue
+		case errors.Is(err, models.ErrIntegrity):
+			held = models.Lock{Name: name}
+			if time.Now().After(deadline) {
+				return nil, tracerr.Wrap(fmt.Errorf("%w: lock %s is unreadable, break it with locks --break", models.ErrConflict, name))
+			}
+		case err != nil:
+			return nil, tracerr.Wrap(err)
+		case held.Stale(time.Now()):
+			if err = u.breakStale(file, held); err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			continue
+		case time.Now().After(deadline):
+			return nil, tracerr.Wrap(fmt.Errorf("%w: %s is locked by %s@%s pid %d until %s", models.ErrConflict, name,
+				held.Owner, held.Host, held.Pid, held.Expires.Format(time.DateTime)))
+		}
+		if !waiting {
+			log.Printf("waiting for lock %s held by %s@%s pid %d", name, held.Owner, held.Host, held.Pid)
+			waiting = true
+		}
+		time.Sleep(_lock_retry)
+	}
+}
+
+// unlock releases lock unless it was broken and taken by another holder
+// meanwhile. Failures are only logged, the lock expires anyway.
+func (u *PackageManager) unlock(lock models.Lock) {
+	file := models.LockFile(lock.Name)
+	held, err := u.readLock(file)
+	if err != nil {
+		log.Printf("releasing lock %s: %v", lock.Name, err)
+		return
+	}
+	if !held.HeldBy(lock) {
+		log.Printf("lock %s was broken and taken by %s@%s pid %d meanwhile", lock.Name, held.Owner, held.Host, held.Pid)
+		return
+	}
+	if err = u.client.RemoveLock(file); err != nil {
+		log.Printf("releasing lock %s: %v", lock.Name, err)
+	}
+}
+
+// breakStale removes the stale lock held from file. Several clients may find
+// the same stale lock, so it is renamed to a name of this client first: only
+// one rename succeeds, and the lock renamed is checked to still be held. A
+// lock taken again meanwhile is put back instead of being removed.
+func (u *PackageManager) breakStale(file string, held models.Lock) error {
+	host, _ := os.Hostname()
+	broken := fmt.Sprintf("%s.%s-%d-%d.broken", file, url.PathEscape(host), os.Getpid(), time.Now().UnixNano())
+	err := u.client.RenameLock(file, broken)
+	if errors.Is(err, models.ErrNotFound) {
+		// broken or released by another client meanwhile
+		return nil
+	}
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	bs, err := u.client.ReadLock(broken)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	renamed := models.Lock{}
+	if json.Unmarshal(bs, &renamed) == nil && renamed.HeldBy(held) {
+		log.Printf("breaking stale lock %s of %s@%s pid %d, expired %s", held.Name, held.Owner, held.Host, held.Pid,
+			held.Expires.Format(time.DateTime))
+	} else if err = u.client.CreateLock(file, bs); errors.Is(err, models.ErrConflict) {
+		log.Printf("warning: lock %s of %s@%s pid %d was taken again before it could be put back", held.Name,
+			renamed.Owner, renamed.Host, renamed.Pid)
+	} else if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err = u.client.RemoveLock(broken); err != nil && !errors.Is(err, models.ErrNotFound) {
+		return tracerr.Wrap(err)
+	}
+	return nil
+}
+
+// breakLock removes lock, whoever holds it with force. Otherwise it is only
+// removed while still the stale lock listed, unreadable ones are anyway.
+func (u *PackageManager) breakLock(lock models.Lock, force bool) error {
+	file := models.LockFile(lock.Name)
+	if !force && !lock.Created.IsZero() {
+		return tracerr.Wrap(u.breakStale(file, lock))
+	}
+	log.Printf("breaking lock %s of %s@%s pid %d", lock.Name, lock.Owner, lock.Host, lock.Pid)
+	if err := u.client.RemoveLock(file); err != nil && !errors.Is(err, models.ErrNotFound) {
+		return tracerr.Wrap(err)
+	}
+	return nil
+}
+
+func (u *PackageManager) readLock(file string) (models.Lock, error) {
+	lock := models.Lock{}
+	bs, err := u.client.ReadLock(file)
+	if err != nil {
+		return lock, tracerr.Wrap(err)
+	}
+	if err = json.Unmarshal(bs, &lock); err != nil {
+		return lock, tracerr.Wrap(fmt.Errorf("%w: lock %s: %w", models.ErrIntegrity, file, err))
+	}
+	return lock, nil
+}
+
+func newLock(name string, ttl time.Duration) models.Lock {
+	lock := models.Lock{Name: name, Pid: os.Getpid(), Created: time.Now().UTC()}
+	lock.Expires = lock.Created.Add(ttl)
+	lock.Host, _ = os.Hostname()
+	if current, err := user.Current(); err == nil {
+		lock.Owner = current.Username
+	} else {
+		lock.Owner = os.Getenv("USER")
+	}
+	return lock
+}
+
+// Locks returns the locks currently held on the storage. Unreadable lock
+// files are listed by name only, they count as stale.
+func (u *PackageManager) Locks() ([]models.Lock, error) {
+	locks := make([]models.Lock, 0)
+	files, err := u.client.GetLocks()
+	if err != nil {
+		return locks, tracerr.Wrap(err)
+	}
+	for _, file := range files {
+		name, ok := models.LockName(file.Name())
+		if !ok {
+			continue
+		}
+		lock, err := u.readLock(file.Name())
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+		if errors.Is(err, models.ErrIntegrity) {
+			lock = models.Lock{Name: name}
+		} else if err != nil {
+			return locks, tracerr.Wrap(err)
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+// BreakLocks removes the locks named, and every stale lock with stale set,
+// whoever holds them. It returns the locks removed, a dry run only lists
+// them.
+func (u *PackageManager) BreakLocks(names []string, stale bool) ([]models.Lock, error) {
+	broken := make([]models.Lock, 0, len(names))
+	locks, err := u.Locks()
+	if err != nil {
+		return broken, tracerr.Wrap(err)
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(locks, func(l models.Lock) bool { return l.Name == name }) {
+			return broken, tracerr.Wrap(fmt.Errorf("%w: lock %s", models.ErrNotFound, name))
+		}
+	}
+	now := time.Now()
+	for _, lock := range locks {
+		if !slices.Contains(names, lock.Name) && !(stale && lock.Stale(now)) {
+			continue
+		}
+		if !u.dryRun {
+			if err = u.breakLock(lock, slices.Contains(names, lock.Name)); err != nil {
+				return broken, tracerr.Wrap(err)
+			}
+		}
+		broken = append(broken, lock)
+	}
+	return broken, nil
+}
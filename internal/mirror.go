@@ -0,0 +1,420 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gammazero/workerpool"
+	"github.com/ztrue/tracerr"
+)
+
+// mirrorJob is a version mirror copies, or only writes the metadata of when
+// the archive is there already.
+type mirrorJob struct {
+	version  models.VersionInfo
+	metadata bool
+	// replaces is the archive of the version on the destination, which is
+	// overwritten or, in another format, removed
+	replaces string
+}
+
+// mirrorPlan is what mirror changes in one package.
+type mirrorPlan struct {
+	name string
+	jobs []mirrorJob
+	tags models.Tags
+}
+
+// Mirror replicates the packages and versions of opts from the storage of u
+// to the one of to, and the tags pointing at them. Versions are compared by
+// digest through both indexes and only missing or changed ones are copied,
+// checked like promote does. Packages are copied by u.workerNum workers, the
+// versions of a package oldest first so delta bases are there before their
+// deltas. The progress is kept in opts.StateFile until the run completes, an
+// interrupted run started again skips what was copied.
+func (u *PackageManager) Mirror(to *PackageManager, opts models.MirrorOptions) ([]models.Result, error) {
+	results := make([]models.Result, 0)
+	state, err := loadMirrorState(opts)
+	if err != nil {
+		return results, tracerr.Wrap(err)
+	}
+	pattern := opts.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	packages, err := u.Search(pattern, opts.Constraint)
+	if err != nil {
+		return results, tracerr.Wrap(err)
+	}
+	to.resetIndex()
+
+	plans := make([]mirrorPlan, 0, len(packages))
+	for _, p := range packages {
+		plan, unchanged, err := u.planMirror(to, p, state)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("mirror", p.Name, "", err))
+		}
+		results = append(results, unchanged...)
+		if len(plan.jobs) > 0 || len(plan.tags) > 0 {
+			plans = append(plans, plan)
+		}
+	}
+	if u.dryRun {
+		for _, plan := range plans {
+			results = append(results, plannedMirror(plan)...)
+		}
+		return sortMirrorResults(results, packages), nil
+	}
+
+	// workers copy archives on their own, changes of the destination index
+	// and of the state are made one at a time
+	var mu sync.Mutex
+	var failed error
+	record := func(result models.Result, meta models.Metadata, file string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if file != "" {
+			err := to.updateIndex(func(index *models.Index) {
+				index.Put(meta.Name, models.IndexVersionOf(meta, file))
+			})
+			if err != nil {
+				return tracerr.Wrap(err)
+			}
+			state.Done[models.VersionLock(meta.Name, meta.Version)] = meta.Digest
+			if err = saveMirrorState(opts.StateFile, state); err != nil {
+				return tracerr.Wrap(err)
+			}
+		}
+		results = append(results, result)
+		return nil
+	}
+	wp := workerpool.New(u.workerNum)
+	for _, plan := range plans {
+		wp.Submit(func() {
+			if err := u.mirrorPackage(to, plan, record); err != nil {
+				mu.Lock()
+				if failed == nil {
+					failed = err
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wp.StopWait()
+	results = sortMirrorResults(results, packages)
+	if failed != nil {
+		return results, tracerr.Wrap(failed)
+	}
+	if opts.StateFile != "" {
+		if err = os.Remove(opts.StateFile); err != nil && !os.IsNotExist(err) {
+			return results, tracerr.Wrap(err)
+		}
+	}
+	return results, nil
+}
+
+// planMirror compares the versions of p on both storages. It returns what
+// has to change and the results of the versions that are up to date.
+func (u *PackageManager) planMirror(to *PackageManager, p models.PackageInfo, state *models.MirrorState) (mirrorPlan, []models.Result, error) {
+	plan := mirrorPlan{name: p.Name, tags: models.Tags{}}
+	unchanged := make([]models.Result, 0)
+	// versions on the destination once the plan ran, tags may point at them
+	available := make(map[string]bool)
+	for _, version := range p.Versions {
+		src, err := u.versionEntry(p.Name, version.Version)
+		if err != nil {
+			return plan, unchanged, tracerr.Wrap(err)
+		}
+		if done, ok := state.Done[models.VersionLock(p.Name, version.Version)]; ok && src.Digest != "" && done == src.Digest {
+			unchanged = append(unchanged, mirrorResult("unchanged", p.Name, version))
+			available[version.Version] = true
+			continue
+		}
+		dst, err := to.versionEntry(p.Name, version.Version)
+		if err != nil {
+			return plan, unchanged, tracerr.Wrap(err)
+		}
+		job := mirrorJob{version: version}
+		switch {
+		case dst == nil:
+		case src.Digest == "" || src.Digest == dst.Digest:
+			if src.Yanked == dst.Yanked {
+				unchanged = append(unchanged, mirrorResult("unchanged", p.Name, version))
+				available[version.Version] = true
+				continue
+			}
+			job.metadata = true
+		default:
+			job.replaces = dst.File
+		}
+		if src.Base != "" && !available[src.Base] {
+			base, err := to.versionEntry(p.Name, src.Base)
+			if err != nil {
+				return plan, unchanged, tracerr.Wrap(err)
+			}
+			if base == nil {
+				return plan, unchanged, tracerr.Wrap(fmt.Errorf("%w: delta base %s of %s is neither selected nor on the destination",
+					models.ErrNotFound, src.Base, version.Version))
+			}
+		}
+		plan.jobs = append(plan.jobs, job)
+		available[version.Version] = true
+	}
+
+	tags, err := u.readTags(p.Name)
+	if err != nil {
+		return plan, unchanged, tracerr.Wrap(err)
+	}
+	mirrored, err := to.readTags(p.Name)
+	if err != nil {
+		return plan, unchanged, tracerr.Wrap(err)
+	}
+	for tag, ver := range tags {
+		if mirrored[tag] == ver {
+			continue
+		}
+		if !available[ver] {
+			// a tag of a version outside the filter is mirrored if the
+			// version is there already
+			entry, err := to.versionEntry(p.Name, ver)
+			if err != nil {
+				return plan, unchanged, tracerr.Wrap(err)
+			}
+			if entry == nil {
+				continue
+			}
+		}
+		plan.tags[tag] = ver
+	}
+	return plan, unchanged, nil
+}
+
+// mirrorPackage runs the jobs of plan in order and passes every version
+// copied to record, then moves the tags.
+func (u *PackageManager) mirrorPackage(to *PackageManager, plan mirrorPlan,
+	record func(result models.Result, meta models.Metadata, file string) error) error {
+	for _, job := range plan.jobs {
+		started := time.Now()
+		ver := job.version.Version
+		meta, err := u.readMetadata(plan.name, ver)
+		if errors.Is(err, models.ErrNotFound) {
+			_, format, _ := models.SplitArchiveName(job.version.File)
+			meta = models.Metadata{Name: plan.name, Version: ver, Format: format, Size: job.version.Size, Uploaded: job.version.ModTime}
+		} else if err != nil {
+			return tracerr.Wrap(models.NewPackageError("mirror", plan.name, ver, err))
+		}
+		result := models.NewResult("mirror", plan.name, ver, started)
+		result.Target = path.Join(plan.name, job.version.File)
+		if job.metadata {
+			log.Printf("package: mirroring the metadata of %s@%s", plan.name, ver)
+			if err = to.writeMetadata(meta); err != nil {
+				return tracerr.Wrap(models.NewPackageError("mirror", plan.name, ver, err))
+			}
+		} else {
+			log.Printf("package: mirroring %s@%s", plan.name, ver)
+			if meta, err = u.mirrorVersion(to, job, meta); err != nil {
+				return tracerr.Wrap(models.NewPackageError("mirror", plan.name, ver, err))
+			}
+			result.Bytes = meta.Size
+		}
+		result.Digest = meta.Digest
+		result.DurationMs = time.Since(started).Milliseconds()
+		if err = record(result, meta, job.version.File); err != nil {
+			return tracerr.Wrap(models.NewPackageError("mirror", plan.name, ver, err))
+		}
+	}
+
+	if len(plan.tags) == 0 {
+		return nil
+	}
+	err := to.changeTags(plan.name, func(stored models.Tags) {
+		for tag, ver := range plan.tags {
+			stored[tag] = ver
+		}
+	})
+	if err != nil {
+		return tracerr.Wrap(models.NewPackageError("mirror", plan.name, "", err))
+	}
+	for _, result := range mirrorTagResults(plan) {
+		if err = record(result, models.Metadata{}, ""); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// mirrorVersion copies the archive of job holding its lock on to, replacing
+// the archive there.
+func (u *PackageManager) mirrorVersion(to *PackageManager, job mirrorJob, meta models.Metadata) (models.Metadata, error) {
+	unlock, err := to.lock(models.VersionLock(meta.Name, meta.Version))
+	if err != nil {
+		return meta, tracerr.Wrap(err)
+	}
+	defer unlock()
+	f := to.client.Upload
+	if job.replaces != "" {
+		f = to.client.Update
+	}
+	meta, err = u.copyVersion(to, path.Join(meta.Name, job.version.File), meta, f)
+	if err != nil {
+		return meta, tracerr.Wrap(err)
+	}
+	// a version published again in another format leaves the previous
+	// archive and its signatures behind. Removing an archive removes the
+	// metadata of its version too, it is written again.
+	if job.replaces != "" && job.replaces != job.version.File {
+		replaced := path.Join(meta.Name, job.replaces)
+		if err = to.client.Remove(replaced); err != nil {
+			return meta, tracerr.Wrap(err)
+		}
+		for _, ext := range models.SignatureExts {
+			if err = to.client.Remove(replaced + "." + ext); err != nil && !errors.Is(err, models.ErrNotFound) {
+				return meta, tracerr.Wrap(err)
+			}
+		}
+		if err = to.writeMetadata(meta); err != nil {
+			return meta, tracerr.Wrap(err)
+		}
+	}
+	return meta, nil
+}
+
+// versionEntry returns the index entry of name@ver, built from its
+// metadata for storages without index. It is nil if the version is not
+// published.
+func (u *PackageManager) versionEntry(name, ver string) (*models.IndexVersion, error) {
+	indexed, err := u.indexedVersion(name, ver)
+	if err != nil || indexed != nil {
+		return indexed, tracerr.Wrap(err)
+	}
+	version, err := u.publishedVersion(name, ver)
+	if err != nil || version == nil {
+		return nil, tracerr.Wrap(err)
+	}
+	meta, err := u.readMetadata(name, ver)
+	if errors.Is(err, models.ErrNotFound) {
+		meta = models.Metadata{Name: name, Version: ver, Size: version.Size(), Uploaded: version.ModTime()}
+	} else if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	entry := models.IndexVersionOf(meta, version.Name())
+	return &entry, nil
+}
+
+func mirrorResult(action, name string, version models.VersionInfo) models.Result {
+	result := models.NewResult(action, name, version.Version, time.Now())
+	result.Target = path.Join(name, version.File)
+	return result
+}
+
+func plannedMirror(plan mirrorPlan) []models.Result {
+	results := make([]models.Result, 0, len(plan.jobs)+len(plan.tags))
+	for _, job := range plan.jobs {
+		result := mirrorResult("mirror", plan.name, job.version)
+		result.Status = models.StatusDryRun
+		if !job.metadata {
+			result.Bytes = job.version.Size
+		}
+		results = append(results, result)
+	}
+	for _, result := range mirrorTagResults(plan) {
+		result.Status = models.StatusDryRun
+		results = append(results, result)
+	}
+	return results
+}
+
+func mirrorTagResults(plan mirrorPlan) []models.Result {
+	results := make([]models.Result, 0, len(plan.tags))
+	for tag, ver := range plan.tags {
+		result := models.NewResult("tag", plan.name, ver, time.Now())
+		result.Target = tag
+		results = append(results, result)
+	}
+	return results
+}
+
+// sortMirrorResults orders results by package and version as listed, tags
+// last.
+func sortMirrorResults(results []models.Result, packages []models.PackageInfo) []models.Result {
+	rank := make(map[string]int)
+	for _, p := range packages {
+		for i, version := range p.Versions {
+			rank[models.VersionLock(p.Name, version.Version)] = i
+		}
+	}
+	key := func(r models.Result) int {
+		if r.Action == "tag" {
+			return len(rank)
+		}
+		return rank[models.VersionLock(r.Package, r.Version)]
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Package != results[j].Package {
+			return results[i].Package < results[j].Package
+		}
+		if key(results[i]) != key(results[j]) {
+			return key(results[i]) < key(results[j])
+		}
+		return results[i].Target < results[j].Target
+	})
+	return results
+}
+
+// loadMirrorState returns the progress of an interrupted run of opts, a new
+// state if there is none.
+func loadMirrorState(opts models.MirrorOptions) (*models.MirrorState, error) {
+	state := &models.MirrorState{
+		Source:      opts.Source,
+		Destination: opts.Destination,
+		Started:     time.Now().UTC(),
+		Done:        map[string]string{},
+	}
+	if opts.StateFile == "" {
+		return state, nil
+	}
+	bs, err := os.ReadFile(opts.StateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	stored := &models.MirrorState{}
+	if err = json.Unmarshal(bs, stored); err != nil {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %s: %w", models.ErrIntegrity, opts.StateFile, err))
+	}
+	if stored.Source != opts.Source || stored.Destination != opts.Destination {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %s holds the progress of mirroring %s to %s", models.ErrConflict,
+			opts.StateFile, stored.Source, stored.Destination))
+	}
+	if stored.Done == nil {
+		stored.Done = map[string]string{}
+	}
+	log.Printf("resuming the mirror started %s, %d versions were copied", stored.Started.Format(time.DateTime), len(stored.Done))
+	return stored, nil
+}
+
+// saveMirrorState replaces the state file, nothing is kept without one.
+func saveMirrorState(file string, state *models.MirrorState) error {
+	if file == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return tracerr.Wrap(err)
+	}
+	bs, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(writeFileAtomic(file, bs))
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error taxonomy shared by PackageManager and every storage backend.
+// Backends wrap their native errors with one of these so callers can
+// react with errors.Is regardless of the transport in use.
+var (
+	ErrVersionExists = errors.New("version already exists")
+	ErrNotFound      = errors.New("not found")
+	ErrIntegrity     = errors.New("integrity check failed")
+	ErrAuth          = errors.New("authentication failed")
+	ErrPermission    = errors.New("permission denied")
+	ErrTransport     = errors.New("transport failure")
+	ErrInvalidInput  = errors.New("invalid input")
+	ErrConflict      = errors.New("conflict")
+)
+
+// PackageError reports the package version and operation an error occurred in.
+type PackageError struct {
+	Op      string
+	Name    string
+	Version string
+	Err     error
+}
+
+func NewPackageError(op, name, version string, err error) *PackageError {
+	return &PackageError{Op: op, Name: name, Version: version, Err: err}
+}
+
+func (e *PackageError) Error() string {
+	if e.Version == "" {
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s %s@%s: %v", e.Op, e.Name, e.Version, e.Err)
+}
+
+func (e *PackageError) Unwrap() error {
+	return e.Err
+}
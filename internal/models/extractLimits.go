@@ -0,0 +1,21 @@
+package models
+
+// ExtractLimits bounds what a fetched archive may unpack to, protecting the
+// output directory against zip bombs.
+type ExtractLimits struct {
+	// MaxEntries is the maximum number of entries in one archive.
+	MaxEntries int
+	// MaxTotalSize is the maximum number of bytes one archive unpacks to.
+	MaxTotalSize int64
+	// MaxRatio is the maximum uncompressed to compressed size ratio of an
+	// entry, checked for entries larger than RatioThreshold bytes.
+	MaxRatio       float64
+	RatioThreshold int64
+}
+
+var DefaultExtractLimits = ExtractLimits{
+	MaxEntries:     100_000,
+	MaxTotalSize:   16 << 30,
+	MaxRatio:       1000,
+	RatioThreshold: 1 << 20,
+}
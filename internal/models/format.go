@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Archive formats a package can be published in. The format is also the
+// extension of the archive on the storage: "<name>/<ver>.<format>".
+const (
+	FormatZip    = "zip"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+	FormatTarXz  = "tar.xz"
+)
+
+// ArchiveFormats lists the supported formats, DefaultFormat first.
+var ArchiveFormats = []string{FormatZip, FormatTarGz, FormatTarZst, FormatTarXz}
+
+const DefaultFormat = FormatZip
+
+// ArchiveFormat returns the format the packet is published in.
+func (p Packets) ArchiveFormat() (string, error) {
+	if p.Format == "" {
+		return DefaultFormat, nil
+	}
+	for _, format := range ArchiveFormats {
+		if p.Format == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown archive format %q, expected one of %s",
+		ErrInvalidInput, p.Format, strings.Join(ArchiveFormats, ", "))
+}
+
+// SignatureExts are the extensions of detached signatures kept next to an
+// archive on the storage: "<name>/<ver>.<format>.<ext>".
+var SignatureExts = []string{"sig", "asc"}
+
+// ArchiveName returns the file name of version ver stored in format.
+func ArchiveName(ver, format string) string {
+	return fmt.Sprintf("%s.%s", ver, format)
+}
+
+// SplitArchiveName splits an archive file name into its version and format.
+// ok is false, and ver is the whole name, if the extension is not a
+// supported format.
+func SplitArchiveName(name string) (ver, format string, ok bool) {
+	for _, format := range ArchiveFormats {
+		if ver, found := strings.CutSuffix(name, "."+format); found && ver != "" {
+			return ver, format, true
+		}
+	}
+	return name, "", false
+}
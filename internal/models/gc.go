@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"path"
+)
+
+// GCPolicy holds the retention rules gc applies. Every package is handled by
+// the first rule matching its name, packages no rule matches are kept whole.
+type GCPolicy struct {
+	Rules []RetentionRule `json:"rules"`
+	// Lockfiles are read like the files given to gc --lockfile
+	Lockfiles []string `json:"lockfiles,omitempty"`
+}
+
+// RetentionRule keeps the versions of the packages matching the Package glob
+// that any of its conditions selects. A version that a kept version is a
+// delta of is kept as well.
+type RetentionRule struct {
+	Package string `json:"package"`
+	// KeepLatest keeps the highest versions
+	KeepLatest int `json:"keep_latest,omitempty"`
+	// KeepDays keeps versions uploaded less than that many days ago
+	KeepDays int `json:"keep_days,omitempty"`
+	// Keep holds version ranges that are never collected, e.g. ">=2.0"
+	Keep []string `json:"keep,omitempty"`
+}
+
+// Rule returns the rule for the package name, or nil.
+func (p GCPolicy) Rule(name string) (*RetentionRule, error) {
+	for i, rule := range p.Rules {
+		ok, err := path.Match(rule.Package, name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d: %w", ErrInvalidInput, i, err)
+		}
+		if ok {
+			return &p.Rules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Lockfile is a packages.json file or an install database; the versions its
+// packages pin are kept by gc. A "ver" without operator is taken as exact,
+// ranges keep every version they match.
+type Lockfile struct {
+	Packages []LockedPackage `json:"packages"`
+}
+
+type LockedPackage struct {
+	Name    string `json:"name"`
+	Ver     string `json:"ver,omitempty"`
+	Version string `json:"version,omitempty"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import "time"
+
+// IndexName is the index of all published versions, kept at the storage
+// root so packages and versions are known without listing directories.
+const IndexName = "index.json"
+
+type Index struct {
+	Updated  time.Time      `json:"updated"`
+	Packages []IndexPackage `json:"packages"`
+}
+
+// IndexPackage lists the published versions of a package, oldest first.
+type IndexPackage struct {
+	Name     string         `json:"name"`
+	Versions []IndexVersion `json:"versions"`
+}
+
+// IndexVersion summarizes the metadata of a version, File is its archive.
+type IndexVersion struct {
+	Version      string     `json:"version"`
+	File         string     `json:"file"`
+	Digest       string     `json:"digest,omitempty"`
+	Size         int64      `json:"size"`
+	Uploaded     time.Time  `json:"uploaded"`
+	Base         string     `json:"base,omitempty"`
+	Dependencies []Packages `json:"dependencies,omitempty"`
+	Yanked       bool       `json:"yanked,omitempty"`
+}
+
+// Package returns the indexed package name, or nil.
+func (idx *Index) Package(name string) *IndexPackage {
+	for i := range idx.Packages {
+		if idx.Packages[i].Name == name {
+			return &idx.Packages[i]
+		}
+	}
+	return nil
+}
+
+// Put records version of the package name, replacing the same version.
+func (idx *Index) Put(name string, version IndexVersion) {
+	p := idx.Package(name)
+	if p == nil {
+		idx.Packages = append(idx.Packages, IndexPackage{Name: name})
+		p = &idx.Packages[len(idx.Packages)-1]
+	}
+	for i := range p.Versions {
+		if p.Versions[i].Version == version.Version {
+			p.Versions[i] = version
+			return
+		}
+	}
+	p.Versions = append(p.Versions, version)
+}
+
+// Delete forgets version of the package name, and the package with its
+// last version.
+func (idx *Index) Delete(name, version string) {
+	for i := range idx.Packages {
+		if idx.Packages[i].Name != name {
+			continue
+		}
+		versions := idx.Packages[i].Versions[:0]
+		for _, v := range idx.Packages[i].Versions {
+			if v.Version != version {
+				versions = append(versions, v)
+			}
+		}
+		idx.Packages[i].Versions = versions
+		if len(versions) == 0 {
+			idx.Packages = append(idx.Packages[:i], idx.Packages[i+1:]...)
+		}
+		return
+	}
+}
+
+// IndexVersionOf returns the index entry of the version meta describes.
+func IndexVersionOf(meta Metadata, file string) IndexVersion {
+	return IndexVersion{
+		Version:      meta.Version,
+		File:         file,
+		Digest:       meta.Digest,
+		Size:         meta.Size,
+		Uploaded:     meta.Uploaded,
+		Base:         meta.Base,
+		Dependencies: meta.Dependencies,
+		Yanked:       meta.Yanked,
+	}
+}
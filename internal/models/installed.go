@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// InstallDBPath is where the install database is kept, relative to the
+// output directory packages are fetched into.
+const InstallDBPath = ".pm/installed.json"
+
+// InstallDB records the packages installed into an output directory and the
+// files each of them owns.
+type InstallDB struct {
+	Packages []InstalledPackage `json:"packages"`
+}
+
+// InstalledPackage is a version installed into Dir, relative to the output
+// directory like the Path of its Files.
+type InstalledPackage struct {
+	Name      string         `json:"name"`
+	Version   string         `json:"version"`
+	Digest    string         `json:"digest,omitempty"`
+	Dir       string         `json:"dir"`
+	Installed time.Time      `json:"installed"`
+	Files     []ManifestFile `json:"files"`
+}
+
+// Installs returns the versions of name installed into any directory.
+func (db *InstallDB) Installs(name string) []InstalledPackage {
+	installs := make([]InstalledPackage, 0, 1)
+	for _, pkg := range db.Packages {
+		if pkg.Name == name {
+			installs = append(installs, pkg)
+		}
+	}
+	return installs
+}
+
+// Find returns the version of name installed into dir, or nil.
+func (db *InstallDB) Find(name, dir string) *InstalledPackage {
+	for i := range db.Packages {
+		if db.Packages[i].Name == name && db.Packages[i].Dir == dir {
+			return &db.Packages[i]
+		}
+	}
+	return nil
+}
+
+// Put records pkg, replacing the version of the same name installed into
+// the same directory.
+func (db *InstallDB) Put(pkg InstalledPackage) {
+	if installed := db.Find(pkg.Name, pkg.Dir); installed != nil {
+		*installed = pkg
+		return
+	}
+	db.Packages = append(db.Packages, pkg)
+}
+
+// Delete forgets the version of name installed into dir.
+func (db *InstallDB) Delete(name, dir string) {
+	for i := range db.Packages {
+		if db.Packages[i].Name == name && db.Packages[i].Dir == dir {
+			db.Packages = append(db.Packages[:i], db.Packages[i+1:]...)
+			return
+		}
+	}
+}
+
+// Owners returns the names of the packages owning each file path.
+func (db *InstallDB) Owners() map[string][]string {
+	owners := make(map[string][]string)
+	for _, pkg := range db.Packages {
+		for _, file := range pkg.Files {
+			owners[file.Path] = append(owners[file.Path], pkg.Name)
+		}
+	}
+	return owners
+}
+
+// InstallCheck is the outcome of verifying the files of an installed
+// package against the digests recorded when it was installed.
+type InstallCheck struct {
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Dir      string   `json:"dir"`
+	Status   string   `json:"status"`
+	Modified []string `json:"modified,omitempty"`
+	Missing  []string `json:"missing,omitempty"`
+}
+
+const StatusModified = "modified"
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LockDir holds the advisory locks at the storage root, one "<name>.lock"
+// file per lock.
+const LockDir = ".locks"
+
+// IndexLock is held while the index is read, changed and written back.
+const IndexLock = "index"
+
+// Lock describes the holder of an advisory lock on the storage. It is only
+// honored by clients taking the same lock, holders crashing leave it behind
+// until it expires.
+type Lock struct {
+	Name    string    `json:"name"`
+	Owner   string    `json:"owner"`
+	Host    string    `json:"host"`
+	Pid     int       `json:"pid"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// VersionLock returns the lock held while name@ver is published.
+func VersionLock(name, ver string) string {
+	return name + "@" + ver
+}
+
+// LockFile returns the file name of the lock name inside LockDir.
+func LockFile(name string) string {
+	return url.PathEscape(name) + ".lock"
+}
+
+// LockName returns the lock name of a file inside LockDir, false for
+// other files.
+func LockName(file string) (string, bool) {
+	escaped, ok := strings.CutSuffix(file, ".lock")
+	if !ok {
+		return "", false
+	}
+	name, err := url.PathUnescape(escaped)
+	return name, err == nil
+}
+
+// Stale reports whether the lock expired by now and may be broken.
+func (l Lock) Stale(now time.Time) bool {
+	return !l.Expires.After(now)
+}
+
+// HeldBy reports whether other is the same acquisition of the lock.
+func (l Lock) HeldBy(other Lock) bool {
+	return l.Host == other.Host && l.Pid == other.Pid && l.Created.Equal(other.Created)
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ManifestName is the archive entry holding the package Manifest. It is
+// written by create and skipped when a package is extracted.
+const ManifestName = ".manifest.json"
+
+// Manifest lists every file of a version. The archive of a delta version
+// only holds the files that changed since Base, the others are taken from
+// the base version.
+type Manifest struct {
+	Name         string         `json:"name"`
+	Version      string         `json:"version"`
+	Base         string         `json:"base,omitempty"`
+	Created      time.Time      `json:"created"`
+	Dependencies []Packages     `json:"dependencies,omitempty"`
+	Files        []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path       string `json:"path"`
+	Mode       string `json:"mode"`
+	Size       int64  `json:"size"`
+	Digest     string `json:"digest,omitempty"`
+	LinkTarget string `json:"link_target,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Metadata is stored next to every published version so it can be inspected
+// without downloading the archive.
+type Metadata struct {
+	Name         string     `json:"name"`
+	Version      string     `json:"version"`
+	Base         string     `json:"base,omitempty"`
+	Digest       string     `json:"digest"`
+	Format       string     `json:"format,omitempty"`
+	Size         int64      `json:"size"`
+	Uploaded     time.Time  `json:"uploaded"`
+	Dependencies []Packages `json:"dependencies,omitempty"`
+	// Yanked versions are withdrawn: ranges no longer resolve to them, an
+	// exact pin still installs them.
+	Yanked bool `json:"yanked,omitempty"`
+	// Trashed and Expires are set while a removed version is kept in the
+	// trash, it is removed for good once it expired.
+	Trashed time.Time `json:"trashed,omitzero"`
+	Expires time.Time `json:"expires,omitzero"`
+}
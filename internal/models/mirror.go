@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// MirrorOptions selects what mirror replicates between two storages.
+type MirrorOptions struct {
+	// Pattern is a glob of package names, Constraint a version range, both
+	// select everything when empty.
+	Pattern    string
+	Constraint string
+	// StateFile keeps the progress of a run so an interrupted one resumes,
+	// no progress is kept if it is empty. Source and Destination name the
+	// storages in it.
+	StateFile   string
+	Source      string
+	Destination string
+}
+
+// MirrorState records the versions a mirror run copied, by "name@ver"
+// with their digest. It is removed once the run completes.
+type MirrorState struct {
+	Source      string            `json:"source"`
+	Destination string            `json:"destination"`
+	Started     time.Time         `json:"started"`
+	Done        map[string]string `json:"done"`
+}
@@ -1,16 +1,79 @@
 package models
 
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
 type Pack struct {
 	Packets []Packets `json:"packets"`
 }
 
 type Packets struct {
-	Name    string    `json:"name"`
-	Ver     string    `json:"ver"`
-	Targets []Targets `json:"targets"`
+	Name         string     `json:"name"`
+	Ver          string     `json:"ver"`
+	Targets      []Targets  `json:"targets"`
+	Dependencies []Packages `json:"dependencies,omitempty"`
+	// Normalize stores fixed modification times and 0644/0755 permissions
+	// instead of the local ones.
+	Normalize bool `json:"normalize,omitempty"`
+	// Reproducible normalizes entries, sorts them by path and fixes the
+	// manifest creation time so identical inputs give identical archives.
+	// It is implied when SOURCE_DATE_EPOCH is set.
+	Reproducible bool `json:"reproducible,omitempty"`
+	// Delta uploads only the files changed since the closest lower
+	// published version, which becomes the base of this one.
+	Delta bool `json:"delta,omitempty"`
+	// Format is one of ArchiveFormats, zip if empty.
+	Format string `json:"format,omitempty"`
+	// Tags are pointed at the version once it is published.
+	Tags []string `json:"tags,omitempty"`
 }
 
+// Targets selects files by Path, a glob that may use "**" for any number of
+// directories; matched directories are packed recursively. Exclude and
+// Excludes use .gitignore syntax and are applied after the .pmignore file.
+// Files are stored under Dest with StripPrefix removed from their local path.
 type Targets struct {
-	Path    string `json:"path"`
-	Exclude string `json:"exclude"`
+	Path        string   `json:"path"`
+	Exclude     string   `json:"exclude"`
+	Excludes    []string `json:"excludes,omitempty"`
+	StripPrefix string   `json:"strip_prefix,omitempty"`
+	Dest        string   `json:"dest,omitempty"`
+}
+
+// ExcludePatterns returns Exclude followed by Excludes.
+func (t Targets) ExcludePatterns() []string {
+	patterns := make([]string, 0, len(t.Excludes)+1)
+	if t.Exclude != "" {
+		patterns = append(patterns, t.Exclude)
+	}
+	return append(patterns, t.Excludes...)
+}
+
+// ArchivePath maps a local file path to its path inside the archive. An
+// empty result means the path is the stripped prefix itself.
+func (t Targets) ArchivePath(localPath string) (string, error) {
+	name := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(localPath)), "./")
+	if t.StripPrefix != "" {
+		prefix := strings.TrimPrefix(path.Clean(filepath.ToSlash(t.StripPrefix)), "./")
+		switch {
+		case prefix == ".":
+		case prefix == name:
+			name = ""
+		case strings.HasPrefix(name, prefix+"/"):
+			name = strings.TrimPrefix(name, prefix+"/")
+		default:
+			return "", fmt.Errorf("%w: %s is not under strip_prefix %s", ErrInvalidInput, localPath, t.StripPrefix)
+		}
+	}
+	if t.Dest != "" && name != "" {
+		name = path.Join(filepath.ToSlash(t.Dest), name)
+	}
+	if name != "" && !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("%w: archive path %s escapes the package root", ErrInvalidInput, name)
+	}
+	return name, nil
 }
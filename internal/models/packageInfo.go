@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// PackageInfo lists the published versions of a package, oldest first.
+type PackageInfo struct {
+	Name     string        `json:"name"`
+	Versions []VersionInfo `json:"versions"`
+}
+
+type VersionInfo struct {
+	Version string    `json:"version"`
+	File    string    `json:"file"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Yanked  bool      `json:"yanked,omitempty"`
+}
+
+// PackageDetails describes one resolved package version and its contents.
+type PackageDetails struct {
+	Metadata
+	Manifest *Manifest  `json:"manifest,omitempty"`
+	Files    []FileInfo `json:"files"`
+}
+
+// FileInfo is an entry of the archive central directory.
+type FileInfo struct {
+	Path           string    `json:"path"`
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressed_size"`
+	Mode           string    `json:"mode"`
+	ModTime        time.Time `json:"mtime"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Result describes the outcome of one package version handled by a command.
+type Result struct {
+	Action     string `json:"action"`
+	Package    string `json:"package"`
+	Version    string `json:"version"`
+	Status     string `json:"status"`
+	Files      int    `json:"files"`
+	Bytes      int64  `json:"bytes"`
+	Digest     string `json:"digest,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	// Target is the remote path or install directory a dry run would
+	// write to or remove, Entries the files it would pack or install.
+	Target  string         `json:"target,omitempty"`
+	Entries []ManifestFile `json:"entries,omitempty"`
+}
+
+const (
+	StatusOk = "ok"
+	// StatusDryRun marks results of actions only planned, not carried out.
+	StatusDryRun = "dry-run"
+)
+
+func NewResult(action, name, version string, started time.Time) Result {
+	return Result{
+		Action:     action,
+		Package:    name,
+		Version:    version,
+		Status:     StatusOk,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TagsName is the sidecar holding the dist-tags of a package, stored as
+// the metadata of the version "<name>/.tags" which is never listed.
+const TagsName = ".tags"
+
+// Tags maps the dist-tags of a package, e.g. "stable", to the version they
+// point at.
+type Tags map[string]string
+
+var tagPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// TagRef returns the tag a version reference of the form "@stable" names.
+func TagRef(ver string) (string, bool) {
+	return strings.CutPrefix(ver, "@")
+}
+
+// ValidateTag checks a tag name, it starts with a letter so it is never
+// taken for a version.
+func ValidateTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("%w: tag %q must start with a letter and hold letters, digits, '.', '_' or '-'", ErrInvalidInput, tag)
+	}
+	return nil
+}
+
+// TagsLock returns the lock held while the tags of name are changed.
+func TagsLock(name string) string {
+	return VersionLock(name, TagsName)
+}
@@ -1,5 +1,11 @@
 package models
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
 type Unpack struct {
 	Packages []Packages `json:"packages"`
 }
@@ -7,4 +13,26 @@ type Unpack struct {
 type Packages struct {
 	Name string `json:"name"`
 	Ver  string `json:"ver,omitempty"`
+	// Dest is the install directory below the output path, it may use the
+	// {name} and {version} placeholders, e.g. "{name}/{version}".
+	Dest string `json:"dest,omitempty"`
+}
+
+// InstallDir expands Dest for a resolved version.
+func (p Packages) InstallDir(version string) (string, error) {
+	if p.Dest == "" {
+		return ".", nil
+	}
+	dir := filepath.Clean(strings.NewReplacer("{name}", p.Name, "{version}", version).Replace(p.Dest))
+	if !filepath.IsLocal(dir) {
+		return "", fmt.Errorf("%w: install path %s escapes the output directory", ErrInvalidInput, dir)
+	}
+	return dir, nil
+}
+
+// ParsePackages parses a "name@version" reference, e.g. "packet-1@>=1.2".
+// The version part is optional.
+func ParsePackages(ref string) Packages {
+	name, ver, _ := strings.Cut(ref, "@")
+	return Packages{Name: name, Ver: ver}
 }
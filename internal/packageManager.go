@@ -3,32 +3,74 @@ package internal
 import (
 	"PackageManager/internal/models"
 	"PackageManager/internal/utils"
-	"archive/zip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	kzip "github.com/klauspost/compress/zip"
 	"github.com/mholt/archives"
 	"github.com/ztrue/tracerr"
 )
 
 type IPackageManager interface {
-	Upload(streamFrom io.ReadWriter, versionStatement string) error
-	Update(streamFrom io.ReadWriter, versionStatement string) error
+	Upload(streamFrom io.Reader, versionStatement string) error
+	Update(streamFrom io.Reader, versionStatement string) error
 	Remove(versionStatement string) error
+	// Move renames a version ("name/ver.format") and its metadata to another
+	// path of the storage, failing with ErrVersionExists if one is there.
+	Move(from, to string) error
 	Download(versionStatement string) (models.IArchiveStream, error)
 	GetVersions(packageName string) ([]os.FileInfo, error)
+	GetPackages() ([]os.FileInfo, error)
+	WriteMeta(versionStatement string, meta []byte) error
+	ReadMeta(versionStatement string) ([]byte, error)
+	// WriteIndex replaces the index at the storage root atomically.
+	WriteIndex(index []byte) error
+	ReadIndex() ([]byte, error)
+	// CreateLock creates a lock file ("<name>.lock") in models.LockDir
+	// only if it does not exist yet, failing with ErrConflict otherwise.
+	CreateLock(file string, lock []byte) error
+	ReadLock(file string) ([]byte, error)
+	RemoveLock(file string) error
+	// RenameLock renames a file in models.LockDir, failing with
+	// ErrNotFound if it is gone.
+	RenameLock(file, to string) error
+	GetLocks() ([]os.FileInfo, error)
 	Close() error
 }
 
 type PackageManager struct {
-	client IPackageManager
+	client        IPackageManager
+	extractLimits models.ExtractLimits
+	// spoolDir is where archives are written before uploading, they are
+	// streamed to the storage if it is empty
+	spoolDir string
+	// cacheDir keeps fetched archives for delta versions built on them,
+	// nothing is cached if it is empty
+	cacheDir string
+	// dryRun reports what commands would change without touching the
+	// storage or the output directory
+	dryRun bool
+	// index is the storage index once loaded, noIndex is set for storages
+	// without one
+	index   *models.Index
+	noIndex bool
+	// lockTTL is how long locks taken on the storage are honored, lockWait
+	// how long a lock held by another client is waited for
+	lockTTL  time.Duration
+	lockWait time.Duration
+	// workerNum is how many packages mirror copies at once
+	workerNum int
 }
 
 func NewRemoteClient(ctx context.Context, up IPackageManager) (*PackageManager, error) {
@@ -38,229 +80,1380 @@ func NewRemoteClient(ctx context.Context, up IPackageManager) (*PackageManager,
 	}
 
 	rClient := &PackageManager{
-		client: up,
+		client:        up,
+		extractLimits: models.DefaultExtractLimits,
+		lockTTL:       _default_lock_ttl,
+		workerNum:     1,
+	}
+	if limits, ok := ctx.Value("extract-limits").(models.ExtractLimits); ok {
+		rClient.extractLimits = limits
+	}
+	if spoolDir, ok := ctx.Value("spool-dir").(string); ok {
+		rClient.spoolDir = spoolDir
+	}
+	if cacheDir, ok := ctx.Value("cache-dir").(string); ok {
+		rClient.cacheDir = cacheDir
+	}
+	if dryRun, ok := ctx.Value("dry-run").(bool); ok {
+		rClient.dryRun = dryRun
+	}
+	if lockTTL, ok := ctx.Value("lock-ttl").(time.Duration); ok && lockTTL > 0 {
+		rClient.lockTTL = lockTTL
+	}
+	if lockWait, ok := ctx.Value("lock-wait").(time.Duration); ok {
+		rClient.lockWait = lockWait
+	}
+	if workerNum, ok := ctx.Value("workerNum").(int); ok && workerNum > 0 {
+		rClient.workerNum = workerNum
 	}
 
 	return rClient, nil
 }
 
-func (u *PackageManager) Create(pack models.Create) error {
-	return u.create(pack, u.client.Upload, "create")
+// Create never overwrites an already published version.
+func (u *PackageManager) Create(pack models.Create) ([]models.Result, error) {
+	return u.create(pack, u.client.Upload, "create", false)
 }
 
-func (u *PackageManager) Update(pack models.Update) error {
-	return u.create(models.Create(pack), u.client.Update, "update")
+// Update overwrites an already published version only when force is set.
+func (u *PackageManager) Update(pack models.Update, force bool) ([]models.Result, error) {
+	return u.create(models.Create(pack), u.client.Update, "update", force)
 }
 
-func (u *PackageManager) Download(unpack models.Read, output string) error {
+func (u *PackageManager) Download(unpack models.Read, output string) ([]models.Result, error) {
 	return u.fetch(unpack, output, u.client.Download)
 }
 
-func (u *PackageManager) Remove(unpack models.Delete) error {
-	return u.delete(unpack, u.client.Remove)
+// Close closes the connection to the storage.
+func (u *PackageManager) Close() error {
+	return u.client.Close()
+}
+
+// Remove moves the matching versions to the trash for retention, or removes
+// them for good if retention is not positive. confirm is asked before
+// removing several versions or a whole package, a nil confirm accepts.
+func (u *PackageManager) Remove(unpack models.Delete, retention time.Duration,
+	confirm func(planned []models.Result) bool) ([]models.Result, error) {
+	return u.delete(unpack, retention, confirm, u.client.Remove)
+}
+
+// List returns every package on the storage with its versions sorted by precedence.
+func (u *PackageManager) List() ([]models.PackageInfo, error) {
+	return u.Search("*", "")
+}
+
+// Search returns the packages whose name matches the glob pattern, keeping
+// only the versions that satisfy constraint (e.g. ">=1.2", empty for all).
+func (u *PackageManager) Search(pattern, constraint string) ([]models.PackageInfo, error) {
+	u.resetIndex()
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrInvalidInput, err))
+	}
+	op, needVer := utils.ParseVersionRef(constraint)
+	packages, err := u.getPackages()
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	ret := make([]models.PackageInfo, 0, len(packages))
+	for _, p := range packages {
+		if ok, _ := path.Match(pattern, p.Name()); !ok {
+			continue
+		}
+		info, err := u.packageInfo(p.Name(), needVer, op)
+		if err != nil {
+			return ret, tracerr.Wrap(models.NewPackageError("search", p.Name(), constraint, err))
+		}
+		if len(info.Versions) > 0 {
+			ret = append(ret, info)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+func (u *PackageManager) packageInfo(name, needVer string, op int) (models.PackageInfo, error) {
+	info := models.PackageInfo{Name: name, Versions: []models.VersionInfo{}}
+	versions, err := u.getVersions(name)
+	if err != nil {
+		return info, tracerr.Wrap(err)
+	}
+	byVersion := make(map[string]os.FileInfo, len(versions))
+	names := make([]string, 0, len(versions))
+	for _, version := range versions {
+		haveVer, _, isArchive := models.SplitArchiveName(version.Name())
+		if !isArchive {
+			log.Printf("package: %s skipping unknown archive %s", name, version.Name())
+			continue
+		}
+		ok, err := utils.CompareVersions(haveVer, needVer, op)
+		if err != nil {
+			log.Printf("package: %s skipping unparsable version %s", name, version.Name())
+			continue
+		}
+		if !ok {
+			continue
+		}
+		byVersion[haveVer] = version
+		names = append(names, haveVer)
+	}
+	utils.SortVersions(names)
+	for _, ver := range names {
+		yanked, err := u.yanked(name, ver)
+		if err != nil {
+			return info, tracerr.Wrap(err)
+		}
+		info.Versions = append(info.Versions, models.VersionInfo{
+			Version: ver,
+			File:    byVersion[ver].Name(),
+			Size:    byVersion[ver].Size(),
+			ModTime: byVersion[ver].ModTime(),
+			Yanked:  yanked,
+		})
+	}
+	return info, nil
+}
+
+// Info resolves the newest version of name matching constraint, as fetch
+// would, and describes it from its stored metadata and the archive entries.
+// A version without operator names that version. For zip archives only the
+// central directory is read through the stream's ReaderAt, the tar formats
+// have to be read in full.
+func (u *PackageManager) Info(name, constraint string) (models.PackageDetails, error) {
+	u.resetIndex()
+	details := models.PackageDetails{Files: []models.FileInfo{}}
+	ref, err := u.resolveTag(models.Packages{Name: name, Ver: constraint})
+	if err != nil {
+		return details, tracerr.Wrap(models.NewPackageError("info", name, constraint, err))
+	}
+	op, needVer := utils.ParseVersionRef(ref.Ver)
+	info, err := u.packageInfo(name, needVer, op)
+	if err != nil {
+		return details, tracerr.Wrap(models.NewPackageError("info", name, constraint, err))
+	}
+	version, ok := latestVersion(info, op)
+	if !ok {
+		return details, tracerr.Wrap(models.NewPackageError("info", name, constraint, models.ErrNotFound))
+	}
+
+	details.Metadata, err = u.readMetadata(name, version.Version)
+	if errors.Is(err, models.ErrNotFound) {
+		details.Metadata = models.Metadata{Name: name, Version: version.Version, Size: version.Size, Uploaded: version.ModTime}
+	} else if err != nil {
+		return details, tracerr.Wrap(models.NewPackageError("info", name, version.Version, err))
+	}
+
+	stream, err := u.client.Download(fmt.Sprintf("%s/%s", name, version.File))
+	if err != nil {
+		return details, tracerr.Wrap(models.NewPackageError("info", name, version.Version, err))
+	}
+	defer stream.Close()
+	details.Files, details.Manifest, err = u.listArchive(stream)
+	if err != nil {
+		return details, tracerr.Wrap(models.NewPackageError("info", name, version.Version, err))
+	}
+	if details.Manifest != nil && len(details.Dependencies) == 0 {
+		details.Dependencies = details.Manifest.Dependencies
+	}
+	return details, nil
+}
+
+// listArchive returns the entries of an archive and its manifest, which is
+// nil for archives created without one.
+func (u *PackageManager) listArchive(stream models.IArchiveStream) ([]models.FileInfo, *models.Manifest, error) {
+	files := make([]models.FileInfo, 0)
+	var manifest *models.Manifest
+	ctx := context.Background()
+	extractor, reader, err := openExtractor(ctx, stream)
+	if err != nil {
+		return files, nil, tracerr.Wrap(err)
+	}
+	err = extractor.Extract(ctx, reader, func(ctx context.Context, f archives.FileInfo) error {
+		if f.NameInArchive == models.ManifestName {
+			manifest, err = u.readManifestEntry(f)
+			return err
+		}
+		file := models.FileInfo{
+			Path:    f.NameInArchive,
+			Size:    f.Size(),
+			Mode:    f.Mode().String(),
+			ModTime: f.ModTime(),
+		}
+		if header, ok := f.Header.(kzip.FileHeader); ok {
+			file.CompressedSize = int64(header.CompressedSize64)
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return files, manifest, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrIntegrity, err))
+	}
+	return files, manifest, nil
 }
 
 // Create method for create and update files on remote storage
 // pack - data from packet.json file
 // f - function (Create/Update) of storage client (sshClient)
-func (u *PackageManager) create(pack models.Create, f func(r io.ReadWriter, dst string) error, action string) error {
+// overwrite - allow replacing a version that is already published
+func (u *PackageManager) create(pack models.Create, f func(r io.Reader, dst string) error, action string, overwrite bool) ([]models.Result, error) {
+	u.resetIndex()
+	results := make([]models.Result, 0, len(pack.Packets))
+	epoch, hasEpoch, err := utils.SourceDateEpoch()
+	if err != nil {
+		return results, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrInvalidInput, err))
+	}
+	// every version is published holding its lock, so concurrent
+	// publishers of the same version do not race
+	var unlock func()
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
 	for _, p := range pack.Packets {
-		localZipPath := fmt.Sprintf("%s_%s.zip", p.Name, p.Ver)
-		zipFile, err := os.Create(localZipPath)
+		started := time.Now()
+		format, err := p.ArchiveFormat()
 		if err != nil {
-			return tracerr.Wrap(err)
+			return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
 		}
-		filesCount := 0
-		zipWriter := zip.NewWriter(zipFile)
-		defer func() {
-			if r := recover(); r != nil {
-				zipWriter.Close()
-				zipFile.Close()
-				os.Remove(localZipPath)
+		for _, tag := range p.Tags {
+			if err = models.ValidateTag(tag); err != nil {
+				return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+			}
+		}
+		if !u.dryRun {
+			if unlock, err = u.lock(models.VersionLock(p.Name, p.Ver)); err != nil {
+				return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
 			}
-		}()
-		for _, t := range p.Targets {
-			matches, err := filepath.Glob(t.Path)
+			// the version may have been published while waiting
+			u.resetIndex()
+		}
+		published, err := u.publishedArchive(p.Name, p.Ver)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+		}
+		exists := published != ""
+		if exists && !overwrite {
+			log.Printf("package: %s@%s is already published, refusing to overwrite it", p.Name, p.Ver)
+			return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, models.ErrVersionExists))
+		}
+		if exists {
+			log.Printf("package: %s@%s is already published, overwriting it", p.Name, p.Ver)
+		} else {
+			log.Printf("package: %s@%s is not published yet, publishing it", p.Name, p.Ver)
+		}
+
+		// SOURCE_DATE_EPOCH asks for reproducible output like the packet option
+		reproducible := p.Reproducible || hasEpoch
+		modTime := normalizedModTime
+		if hasEpoch && epoch.After(modTime) {
+			modTime = epoch
+		}
+		created := time.Now().UTC()
+		if reproducible {
+			created = modTime
+		}
+		files, err := u.collectFiles(p.Targets)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+		}
+		if reproducible {
+			sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+		}
+
+		var base map[string]models.ManifestFile
+		baseVer := ""
+		if p.Delta {
+			baseVer, base, err = u.deltaBase(p.Name, p.Ver)
 			if err != nil {
-				return tracerr.Wrap(err)
+				return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
 			}
-			for _, match := range matches {
-				exclude, err := filepath.Match(t.Exclude, filepath.Base(match))
-				if err != nil {
-					return tracerr.Wrap(err)
+		}
+
+		archiveName := models.ArchiveName(p.Ver, format)
+		if u.dryRun {
+			result, err := plannedCreate(action, p, files, base, p.Normalize || reproducible, started)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+			}
+			result.Target = fmt.Sprintf("%s/%s", p.Name, archiveName)
+			results = append(results, result)
+			continue
+		}
+
+		filesCount := 0
+		manifest := models.Manifest{
+			Name:         p.Name,
+			Version:      p.Ver,
+			Base:         baseVer,
+			Created:      created,
+			Dependencies: p.Dependencies,
+			Files:        []models.ManifestFile{},
+		}
+		build := func(w io.Writer) error {
+			archiveWriter, err := newArchiveWriter(w, format, reproducible)
+			if err != nil {
+				return err
+			}
+			for _, match := range files {
+				if base != nil {
+					file, unchanged, err := unchangedEntry(base, match.Path, match.Name, p.Normalize || reproducible)
+					if err != nil {
+						archiveWriter.Close()
+						return err
+					}
+					if unchanged {
+						manifest.Files = append(manifest.Files, file)
+						continue
+					}
 				}
-				stat, err := os.Stat(match)
+				file, err := u.createArchiveEntry(archiveWriter, match.Path, match.Name, p.Normalize || reproducible, modTime)
 				if err != nil {
-					return tracerr.Wrap(err)
+					archiveWriter.Close()
+					return err
 				}
-				if !exclude && !stat.IsDir() {
-					if err := u.createArchiveEntry(zipWriter, match); err != nil {
-						return tracerr.Wrap(err)
-					}
+				manifest.Files = append(manifest.Files, file)
+				if !strings.HasPrefix(file.Mode, "d") {
 					filesCount++
 				}
 			}
+			if err := u.createManifestEntry(archiveWriter, manifest); err != nil {
+				archiveWriter.Close()
+				return err
+			}
+			return tracerr.Wrap(archiveWriter.Close())
 		}
-		zipWriter.Close()
-		zipFile.Close()
-		err = u.actionZipArchive(localZipPath, fmt.Sprintf("%s/%s", p.Name, p.Ver), os.O_RDONLY, f)
+		digest, size, err := u.upload(build, fmt.Sprintf("%s/%s", p.Name, archiveName), f)
 		if err != nil {
-			return tracerr.Wrap(err)
+			return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+		}
+		if base != nil {
+			log.Printf("package: %s@%s is a delta of %s, %d of %d files changed", p.Name, p.Ver, baseVer, filesCount, len(files))
+		}
+		// an overwrite in another format leaves the previous archive behind
+		if exists && published != archiveName {
+			if err = u.client.Remove(fmt.Sprintf("%s/%s", p.Name, published)); err != nil {
+				return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+			}
+		}
+
+		meta := models.Metadata{
+			Name:         p.Name,
+			Version:      p.Ver,
+			Base:         baseVer,
+			Digest:       digest,
+			Format:       format,
+			Size:         size,
+			Uploaded:     time.Now().UTC(),
+			Dependencies: p.Dependencies,
 		}
-		os.Remove(localZipPath)
-		log.Printf("package: %s@%s with %d files was %s", p.Name, p.Ver, filesCount, action)
+		if err = u.writeMetadata(meta); err != nil {
+			return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+		}
+		err = u.updateIndex(func(index *models.Index) {
+			index.Put(p.Name, models.IndexVersionOf(meta, archiveName))
+		})
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError(action, p.Name, p.Ver, err))
+		}
+		if len(p.Tags) > 0 {
+			if _, err = u.tag(p.Name, p.Ver, p.Tags); err != nil {
+				return results, tracerr.Wrap(err)
+			}
+		}
+
+		result := models.NewResult(action, p.Name, p.Ver, started)
+		result.Files = filesCount
+		result.Bytes = size
+		result.Digest = digest
+		results = append(results, result)
+		unlock()
+		unlock = nil
 	}
-	return nil
+	return results, nil
 }
 
-func (u *PackageManager) fetch(unpack models.Read, output string, f func(versionStatement string) (models.IArchiveStream, error)) error {
+func (u *PackageManager) fetch(unpack models.Read, output string, f func(versionStatement string) (models.IArchiveStream, error)) ([]models.Result, error) {
+	u.resetIndex()
+	results := make([]models.Result, 0, len(unpack.Packages))
+	db, err := loadInstallDB(output)
+	if err != nil {
+		return results, tracerr.Wrap(err)
+	}
 	for _, p := range unpack.Packages {
-		op, needVer := utils.ParseVersion(p.Ver)
-		versions, err := u.client.GetVersions(p.Name)
+		p, err := u.resolveTag(p)
 		if err != nil {
-			return tracerr.Wrap(err)
+			return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, p.Ver, err))
+		}
+		op, needVer := utils.ParseVersionRef(p.Ver)
+		versions, err := u.getVersions(p.Name)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, p.Ver, err))
 		}
 		for _, version := range versions {
-			haveVer := strings.Replace(version.Name(), filepath.Ext(version.Name()), "", -1)
+			started := time.Now()
+			haveVer, _, isArchive := models.SplitArchiveName(version.Name())
+			if !isArchive {
+				continue
+			}
 			ok, err := utils.CompareVersions(haveVer, needVer, op)
 			if err != nil {
-				return tracerr.Wrap(err)
+				return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, p.Ver, fmt.Errorf("%w: %w", models.ErrInvalidInput, err)))
 			}
 			if !ok {
 				continue
 			}
+			yanked, err := u.yanked(p.Name, haveVer)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, haveVer, err))
+			}
+			if !resolvable(p.Name, models.VersionInfo{Version: haveVer, Yanked: yanked}, op) {
+				continue
+			}
 
-			log.Println(fmt.Sprintf("fetching %s to %s...", filepath.Base(p.Name), output))
+			dir, err := p.InstallDir(haveVer)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, haveVer, err))
+			}
+			installDir := filepath.Join(output, dir)
+			dir = path.Clean(filepath.ToSlash(dir))
 
-			versionStatement := fmt.Sprintf("%s/%s", p.Name, version.Name())
+			// files of a version installed there before are replaced. The ones
+			// modified locally are never deleted, but the new archive still
+			// overwrites those it contains
+			previous := make([]models.InstalledPackage, 0, 1)
+			if installed := db.Find(p.Name, dir); installed != nil {
+				modified, _, err := checkInstalled(output, installed)
+				if err != nil {
+					return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, haveVer, err))
+				}
+				previous = append(previous, withoutFiles(*installed, modified))
+			}
 
-			packageStream, err := f(versionStatement)
-			if err != nil {
-				return tracerr.Wrap(err)
+			if u.dryRun {
+				result, err := u.plannedFetch(p.Name, haveVer, version.Name(), f, started)
+				if err != nil {
+					return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, haveVer, err))
+				}
+				result.Target = installDir
+				results = append(results, result)
+				continue
 			}
 
-			err = u.handleArchive(output, packageStream)
+			log.Println(fmt.Sprintf("fetching %s to %s...", filepath.Base(p.Name), installDir))
+
+			ex, err := u.install(p.Name, haveVer, version.Name(), installDir, f)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, haveVer, err))
+			}
+			files, err := ownedFiles(installDir, dir, ex)
 			if err != nil {
-				return tracerr.Wrap(err)
+				return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, haveVer, err))
 			}
+			err = recordInstall(output, db, models.InstalledPackage{
+				Name:      p.Name,
+				Version:   haveVer,
+				Digest:    ex.Digest,
+				Dir:       dir,
+				Installed: time.Now().UTC(),
+				Files:     files,
+			}, previous)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, haveVer, err))
+			}
+
+			result := models.NewResult("fetch", p.Name, haveVer, started)
+			result.Files = ex.Files
+			result.Bytes = ex.Bytes
+			result.Digest = ex.Digest
+			results = append(results, result)
 		}
 	}
-	return nil
+	return results, nil
 }
 
-func (u *PackageManager) delete(unpack models.Delete, f func(rm string) error) error {
+// delete removes the versions of unpack in two steps: every match is
+// planned first, and nothing is removed unless confirm accepts the plan when
+// it holds several versions or an unconstrained range. With a positive
+// retention removed versions are moved to the trash of their package until
+// they expire, otherwise they are removed through f.
+func (u *PackageManager) delete(unpack models.Delete, retention time.Duration, confirm func(planned []models.Result) bool,
+	f func(rm string) error) ([]models.Result, error) {
+	u.resetIndex()
+	results := make([]models.Result, 0, len(unpack.Packages))
+	planned := make([]models.Result, 0, len(unpack.Packages))
+	unconstrained := false
 	for _, p := range unpack.Packages {
-		op, needVer := utils.ParseVersion(p.Ver)
-		versions, err := u.client.GetVersions(p.Name)
+		started := time.Now()
+		op, needVer := utils.ParseVersionRef(p.Ver)
+		versions, err := u.getVersions(p.Name)
 		if err != nil {
-			return tracerr.Wrap(err)
+			return results, tracerr.Wrap(models.NewPackageError("remove", p.Name, p.Ver, err))
 		}
+		matched := make(map[string]bool)
+		kept := make([]string, 0)
 		for _, version := range versions {
-			haveVer := strings.Replace(version.Name(), filepath.Ext(version.Name()), "", -1)
+			haveVer, _, isArchive := models.SplitArchiveName(version.Name())
+			if !isArchive {
+				continue
+			}
 			ok, err := utils.CompareVersions(haveVer, needVer, op)
 			if err != nil {
-				return tracerr.Wrap(err)
+				return results, tracerr.Wrap(models.NewPackageError("remove", p.Name, p.Ver, fmt.Errorf("%w: %w", models.ErrInvalidInput, err)))
 			}
-			if !ok {
-				continue
+			if ok {
+				matched[haveVer] = true
+			} else {
+				kept = append(kept, haveVer)
 			}
+		}
+		if err = u.checkDeltaBases(p.Name, matched, kept); err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("remove", p.Name, p.Ver, err))
+		}
+		unconstrained = unconstrained || (op == utils.ALL && len(matched) > 0)
 
-			log.Println(fmt.Sprintf("removing from %s package...", p.Name))
-
-			err = f(filepath.Join(p.Name, version.Name()))
-			if err != nil {
-				return tracerr.Wrap(err)
+		for _, version := range versions {
+			haveVer, _, _ := models.SplitArchiveName(version.Name())
+			if !matched[haveVer] {
+				continue
 			}
+			result := models.NewResult("remove", p.Name, haveVer, started)
+			result.Status = models.StatusDryRun
+			result.Bytes = version.Size()
+			result.Target = path.Join(p.Name, version.Name())
+			planned = append(planned, result)
+		}
+	}
+	if u.dryRun {
+		return planned, nil
+	}
+	if (len(planned) > 1 || unconstrained) && confirm != nil && !confirm(planned) {
+		return results, tracerr.Wrap(fmt.Errorf("%w: removing %d versions was not confirmed", models.ErrInvalidInput, len(planned)))
+	}
+
+	expires := time.Now().Add(retention).UTC()
+	for _, plan := range planned {
+		started := time.Now()
+		log.Println(fmt.Sprintf("removing from %s package...", plan.Package))
+		if err := u.removeVersion(plan, retention, expires, f); err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("remove", plan.Package, plan.Version, err))
 		}
 
+		result := models.NewResult("remove", plan.Package, plan.Version, started)
+		result.Bytes = plan.Bytes
+		results = append(results, result)
 	}
-	return nil
+	for _, p := range unpack.Packages {
+		if err := u.purgeTrash(p.Name, time.Now(), f); err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("remove", p.Name, p.Ver, err))
+		}
+	}
+	return results, nil
 }
 
-func (u *PackageManager) actionZipArchive(localPath, versionStatement string,
-	openFlags int, f func(f io.ReadWriter, dst string) error) error {
-	archiveFileStream, err := os.OpenFile(localPath, openFlags, 0666)
+// removeVersion removes the version planned, or moves it to the trash with
+// a positive retention, holding its lock.
+func (u *PackageManager) removeVersion(plan models.Result, retention time.Duration, expires time.Time,
+	f func(rm string) error) error {
+	unlock, err := u.lock(models.VersionLock(plan.Package, plan.Version))
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			archiveFileStream.Close()
-		}
-	}()
-	err = f(archiveFileStream, versionStatement)
+	defer unlock()
+	if retention > 0 {
+		err = u.trash(plan.Package, plan.Version, path.Base(plan.Target), expires, f)
+	} else {
+		err = f(plan.Target)
+	}
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
-	if err = archiveFileStream.Close(); err != nil {
+	return tracerr.Wrap(u.updateIndex(func(index *models.Index) {
+		index.Delete(plan.Package, plan.Version)
+	}))
+}
+
+// extraction describes what was written when installing a version.
+type extraction struct {
+	// Manifest of the version, nil for archives created without one
+	Manifest *models.Manifest
+	// Entries are the archive paths written, directories included
+	Entries []string
+	Files   int
+	Bytes   int64
+	// Digest of the archive as published, empty for versions uploaded
+	// without metadata
+	Digest string
+}
+
+// install extracts name@ver from its archive into installDir. The base of a
+// delta version is installed first, then the delta is applied on top of it
+// and the base files it no longer contains are removed.
+func (u *PackageManager) install(name, ver, archive, installDir string,
+	f func(versionStatement string) (models.IArchiveStream, error)) (extraction, error) {
+	meta, err := u.readMetadata(name, ver)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return extraction{}, tracerr.Wrap(err)
+	}
+
+	var base *extraction
+	if meta.Base != "" {
+		// a base is always a lower version, anything else would never end
+		if cmp, err := utils.CompareVersionNumbers(meta.Base, ver); err != nil || cmp >= 0 {
+			return extraction{}, tracerr.Wrap(fmt.Errorf("%w: %s@%s can not be a delta of %s", models.ErrIntegrity, name, ver, meta.Base))
+		}
+		baseArchive, err := u.publishedArchive(name, meta.Base)
+		if err != nil {
+			return extraction{}, tracerr.Wrap(err)
+		}
+		if baseArchive == "" {
+			return extraction{}, tracerr.Wrap(fmt.Errorf("%w: delta base %s@%s of %s", models.ErrNotFound, name, meta.Base, ver))
+		}
+		log.Printf("package: %s@%s is a delta of %s, installing the base first", name, ver, meta.Base)
+		ex, err := u.install(name, meta.Base, baseArchive, installDir, f)
+		if err != nil {
+			return extraction{}, tracerr.Wrap(err)
+		}
+		base = &ex
+	}
+
+	stream, err := u.openArchive(name, archive, meta.Digest, f)
+	if err != nil {
+		return extraction{}, tracerr.Wrap(err)
+	}
+	ex, err := u.handleArchive(installDir, stream)
+	if err != nil {
+		return ex, tracerr.Wrap(err)
+	}
+	ex.Digest = meta.Digest
+	if base == nil {
+		return ex, nil
+	}
+
+	if ex.Manifest == nil || base.Manifest == nil {
+		return ex, tracerr.Wrap(fmt.Errorf("%w: delta %s@%s or its base has no manifest", models.ErrIntegrity, name, ver))
+	}
+	if err = pruneBase(installDir, base.Manifest, ex.Manifest); err != nil {
+		return ex, tracerr.Wrap(err)
+	}
+	ex.Bytes += base.Bytes
+	ex.Entries = make([]string, 0, len(ex.Manifest.Files))
+	ex.Files = 0
+	for _, file := range ex.Manifest.Files {
+		ex.Entries = append(ex.Entries, file.Path)
+		if !strings.HasPrefix(file.Mode, "d") {
+			ex.Files++
+		}
+	}
+	return ex, nil
+}
+
+// pruneBase removes the files of the base version that the delta on top of
+// it no longer contains.
+func pruneBase(installDir string, base, delta *models.Manifest) error {
+	keep := make(map[string]bool, len(delta.Files))
+	for _, file := range delta.Files {
+		keep[file.Path] = true
+	}
+	stale := make([]string, 0)
+	for _, file := range base.Files {
+		if !keep[file.Path] {
+			stale = append(stale, file.Path)
+		}
+	}
+	return removeFiles(installDir, stale, keep)
+}
+
+// removeFiles removes the slash separated paths below root, and the parent
+// directories left empty, without following symlinks out of root. Paths in
+// keep are left alone, and so are directories that are not empty.
+func removeFiles(root string, paths []string, keep map[string]bool) error {
+	// entries below a directory sort after it
+	paths = append([]string{}, paths...)
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+
+	root, err := filepath.EvalSymlinks(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
 		return tracerr.Wrap(err)
 	}
+	for _, name := range paths {
+		if keep[name] {
+			continue
+		}
+		name, err := sanitizeArchivePath(name)
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if err = removeInside(root, name); err != nil {
+			return tracerr.Wrap(err)
+		}
+		// parents are cleaned up even when the file was already gone
+		for dir := path.Dir(filepath.ToSlash(name)); dir != "." && !keep[dir]; dir = path.Dir(dir) {
+			if os.Remove(filepath.Join(root, filepath.FromSlash(dir))) != nil {
+				break
+			}
+		}
+	}
 	return nil
 }
 
-func (u *PackageManager) createArchiveEntry(zipWriter *zip.Writer, filepath_ string) error {
-	entry, err := zipWriter.Create(filepath_)
+// removeInside removes the file, symlink or empty directory name below root,
+// if it is still there.
+func removeInside(root, name string) error {
+	parent, err := realPathInside(root, filepath.Dir(filepath.Join(root, name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
-	localFile, err := os.Open(filepath_)
+	filepath_ := filepath.Join(parent, filepath.Base(name))
+	info, err := os.Lstat(filepath_)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			localFile.Close()
+	err = os.Remove(filepath_)
+	// a directory may still hold files of another version or package
+	if err != nil && !info.IsDir() {
+		return tracerr.Wrap(err)
+	}
+	return nil
+}
+
+// openArchive opens the archive of a version, from the cache directory if it
+// holds a copy with the expected digest. Downloaded archives are added to the
+// cache, so a delta can later be applied without fetching its base again.
+func (u *PackageManager) openArchive(name, archive, digest string,
+	f func(versionStatement string) (models.IArchiveStream, error)) (models.IArchiveStream, error) {
+	versionStatement := fmt.Sprintf("%s/%s", name, archive)
+	if u.cacheDir == "" {
+		stream, err := f(versionStatement)
+		return stream, tracerr.Wrap(err)
+	}
+
+	cached := filepath.Join(u.cacheDir, name, archive)
+	if digest != "" {
+		if have, _, err := utils.DigestFile(cached); err == nil && have == digest {
+			stream, err := os.Open(cached)
+			return stream, tracerr.Wrap(err)
+		}
+	}
+
+	stream, err := f(versionStatement)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer stream.Close()
+	if err = os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	download, err := os.CreateTemp(filepath.Dir(cached), ".download-*")
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	defer os.Remove(download.Name())
+	have := utils.NewDigestWriter()
+	_, err = io.Copy(io.MultiWriter(download, have), stream)
+	if closeErr := download.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if digest != "" && have.Digest() != digest {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %s has digest %s, expected %s", models.ErrIntegrity, versionStatement, have.Digest(), digest))
+	}
+	if err = os.Rename(download.Name(), cached); err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	cachedStream, err := os.Open(cached)
+	return cachedStream, tracerr.Wrap(err)
+}
+
+// checkDeltaBases refuses removing versions that a kept version is a delta of.
+func (u *PackageManager) checkDeltaBases(name string, removed map[string]bool, kept []string) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	for _, ver := range kept {
+		meta, err := u.readMetadata(name, ver)
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		if removed[meta.Base] {
+			return tracerr.Wrap(fmt.Errorf("%w: %s@%s is the delta base of %s, remove both together", models.ErrInvalidInput, name, meta.Base, ver))
+		}
+	}
+	return nil
+}
+
+// archiveEntry is a local file and the path it is stored under in the archive.
+type archiveEntry struct {
+	Path string
+	Name string
+}
+
+// collectFiles expands the targets into the list of files, symlinks and
+// empty directories to pack. Matched directories are walked without following
+// symlinks, excluded directories are skipped as a whole and an entry matched
+// by several targets is packed once.
+func (u *PackageManager) collectFiles(targets []models.Targets) ([]archiveEntry, error) {
+	ignored, err := utils.ReadIgnoreFile(utils.IgnoreFileName)
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	files := make([]archiveEntry, 0)
+	seen := make(map[string]bool)
+	names := make(map[string]string)
+	for _, t := range targets {
+		ignore, err := utils.NewIgnore(append(ignored, t.ExcludePatterns()...))
+		if err != nil {
+			return nil, tracerr.Wrap(fmt.Errorf("%w: exclude: %w", models.ErrInvalidInput, err))
 		}
+		matches, err := utils.Glob(t.Path)
+		if err != nil {
+			return nil, tracerr.Wrap(fmt.Errorf("%w: path: %w", models.ErrInvalidInput, err))
+		}
+		for _, match := range matches {
+			err = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if ignore.Excluded(path, d.IsDir()) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if d.IsDir() {
+					entries, err := os.ReadDir(path)
+					if err != nil || len(entries) > 0 {
+						return err
+					}
+				}
+				if seen[path] {
+					return nil
+				}
+				seen[path] = true
+				name, err := t.ArchivePath(path)
+				if err != nil || name == "" {
+					return err
+				}
+				if other, ok := names[name]; ok {
+					return fmt.Errorf("%w: %s and %s are both packed as %s", models.ErrInvalidInput, other, path, name)
+				}
+				names[name] = path
+				files = append(files, archiveEntry{Path: path, Name: name})
+				return nil
+			})
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+		}
+	}
+	return files, nil
+}
+
+// deltaBase returns the closest published version below ver and the files
+// of its manifest by path. No base is returned, and the version is packed in
+// full, if there is no lower version or it was created without a manifest.
+func (u *PackageManager) deltaBase(name, ver string) (string, map[string]models.ManifestFile, error) {
+	info, err := u.packageInfo(name, ver, utils.LESS_THEN)
+	if errors.Is(err, models.ErrNotFound) || (err == nil && len(info.Versions) == 0) {
+		log.Printf("package: %s@%s has no lower version to be a delta of, packing it in full", name, ver)
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, tracerr.Wrap(err)
+	}
+	base := info.Versions[len(info.Versions)-1]
+	stream, err := u.client.Download(fmt.Sprintf("%s/%s", name, base.File))
+	if err != nil {
+		return "", nil, tracerr.Wrap(err)
+	}
+	defer stream.Close()
+	_, manifest, err := u.listArchive(stream)
+	if err != nil {
+		return "", nil, tracerr.Wrap(err)
+	}
+	if manifest == nil {
+		log.Printf("package: %s@%s has no manifest to be a delta base, packing %s in full", name, base.Version, ver)
+		return "", nil, nil
+	}
+	files := make(map[string]models.ManifestFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		files[file.Path] = file
+	}
+	return base.Version, files, nil
+}
+
+// unchangedEntry reports whether the local entry is stored in base with the
+// same content, mode and link target and returns its base manifest record.
+// Modification times are not compared.
+func unchangedEntry(base map[string]models.ManifestFile, filepath_, name string, normalize bool) (models.ManifestFile, bool, error) {
+	old, ok := base[name]
+	if !ok {
+		return old, false, nil
+	}
+	info, err := os.Lstat(filepath_)
+	if err != nil {
+		return old, false, tracerr.Wrap(err)
+	}
+	mode := info.Mode()
+	if normalize {
+		mode = normalizeMode(mode)
+	}
+	if mode.String() != old.Mode {
+		return old, false, nil
+	}
+	switch {
+	case info.IsDir():
+		return old, true, nil
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(filepath_)
+		return old, target == old.LinkTarget, tracerr.Wrap(err)
+	case info.Size() != old.Size:
+		return old, false, nil
+	}
+	digest, _, err := utils.DigestFile(filepath_)
+	return old, digest == old.Digest, tracerr.Wrap(err)
+}
+
+// publishedArchive returns the archive name name@ver is published as on the
+// storage, in any format, or "" if it is not published.
+func (u *PackageManager) publishedArchive(name, ver string) (string, error) {
+	version, err := u.publishedVersion(name, ver)
+	if err != nil || version == nil {
+		return "", tracerr.Wrap(err)
+	}
+	return version.Name(), nil
+}
+
+// publishedVersion returns the archive of name@ver, nil if it is not
+// published.
+func (u *PackageManager) publishedVersion(name, ver string) (os.FileInfo, error) {
+	versions, err := u.getVersions(name)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	for _, version := range versions {
+		if haveVer, _, ok := models.SplitArchiveName(version.Name()); ok && haveVer == ver {
+			return version, nil
+		}
+	}
+	return nil, nil
+}
+
+// upload streams the archive written by build to the storage through f and
+// returns its digest and size. Nothing is written locally unless a spool
+// directory is set, then the archive is written to a temporary file there
+// first for backends that need to know its length.
+func (u *PackageManager) upload(build func(w io.Writer) error, versionStatement string,
+	f func(r io.Reader, dst string) error) (string, int64, error) {
+	digest := utils.NewDigestWriter()
+	if u.spoolDir != "" {
+		spool, err := os.CreateTemp(u.spoolDir, ".pm-spool-*")
+		if err != nil {
+			return "", 0, tracerr.Wrap(err)
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		if err = build(io.MultiWriter(spool, digest)); err != nil {
+			return "", 0, tracerr.Wrap(err)
+		}
+		if _, err = spool.Seek(0, io.SeekStart); err != nil {
+			return "", 0, tracerr.Wrap(err)
+		}
+		if err = f(spool, versionStatement); err != nil {
+			return "", 0, tracerr.Wrap(err)
+		}
+		return digest.Digest(), digest.Size(), nil
+	}
+
+	reader, writer := io.Pipe()
+	built := make(chan error, 1)
+	go func() {
+		err := build(io.MultiWriter(writer, digest))
+		// a nil error ends the stream with io.EOF
+		writer.CloseWithError(err)
+		built <- err
 	}()
-	_, err = io.Copy(entry, localFile)
+	err := f(reader, versionStatement)
+	// unblocks build if the upload stopped reading early
+	reader.CloseWithError(io.ErrClosedPipe)
+	buildErr := <-built
+	if err != nil {
+		return "", 0, tracerr.Wrap(err)
+	}
+	if buildErr != nil {
+		return "", 0, tracerr.Wrap(buildErr)
+	}
+	return digest.Digest(), digest.Size(), nil
+}
+
+// createArchiveEntry writes the local file, symlink or empty directory into
+// the archive as name with its mode and modification time and returns its
+// manifest record. normalize replaces both with modTime and a normalized mode
+// for reproducible packages.
+func (u *PackageManager) createArchiveEntry(archiveWriter archiveWriter, filepath_, name string, normalize bool, modTime time.Time) (models.ManifestFile, error) {
+	file := models.ManifestFile{Path: name}
+	info, err := os.Lstat(filepath_)
+	if err != nil {
+		return file, tracerr.Wrap(err)
+	}
+	header := entryHeader{Name: name, Mode: info.Mode(), ModTime: info.ModTime(), Size: info.Size()}
+	if normalize {
+		header.ModTime = modTime
+		header.Mode = normalizeMode(info.Mode())
+	}
+	file.Mode = header.Mode.String()
+
+	switch {
+	case info.IsDir():
+		_, err = archiveWriter.CreateEntry(header)
+		return file, tracerr.Wrap(err)
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(filepath_)
+		if err != nil {
+			return file, tracerr.Wrap(err)
+		}
+		header.LinkTarget = target
+		header.Size = int64(len(target))
+		entry, err := archiveWriter.CreateEntry(header)
+		if err != nil {
+			return file, tracerr.Wrap(err)
+		}
+		file.LinkTarget = target
+		file.Digest, file.Size, err = utils.DigestReader(io.TeeReader(strings.NewReader(target), entry))
+		return file, tracerr.Wrap(err)
+	}
+
+	localFile, err := os.Open(filepath_)
+	if err != nil {
+		return file, tracerr.Wrap(err)
+	}
+	defer localFile.Close()
+	entry, err := archiveWriter.CreateEntry(header)
+	if err != nil {
+		return file, tracerr.Wrap(err)
+	}
+	// the tar header already holds the size, a file growing meanwhile
+	// must not overrun it
+	file.Digest, file.Size, err = utils.DigestReader(io.TeeReader(io.LimitReader(localFile, header.Size), entry))
 	if err != nil {
+		return file, tracerr.Wrap(err)
+	}
+	return file, nil
+}
+
+// normalizedModTime is the earliest time representable in a zip header. It
+// is used for normalized entries, or SOURCE_DATE_EPOCH if that is later.
+var normalizedModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// normalizeMode keeps only the entry type and whether it is executable.
+func normalizeMode(mode fs.FileMode) fs.FileMode {
+	switch {
+	case mode.IsDir():
+		return fs.ModeDir | 0755
+	case mode&fs.ModeSymlink != 0:
+		return fs.ModeSymlink | 0777
+	case mode&0111 != 0:
+		return 0755
+	}
+	return 0644
+}
+
+func (u *PackageManager) createManifestEntry(archiveWriter archiveWriter, manifest models.Manifest) error {
+	bs, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	entry, err := archiveWriter.CreateEntry(entryHeader{
+		Name:    models.ManifestName,
+		Mode:    0644,
+		ModTime: manifest.Created,
+		Size:    int64(len(bs)),
+	})
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if _, err = entry.Write(bs); err != nil {
 		return tracerr.Wrap(err)
 	}
 	return nil
 }
 
-func (u *PackageManager) handleArchive(output string, packageStream models.IArchiveStream) error {
-	fs_, err := archives.FileSystem(context.Background(), "", packageStream)
+func (u *PackageManager) readManifestEntry(f archives.FileInfo) (*models.Manifest, error) {
+	entry, err := f.Open()
+	if err != nil {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrIntegrity, err))
+	}
+	defer entry.Close()
+	manifest := &models.Manifest{}
+	if err = json.NewDecoder(entry).Decode(manifest); err != nil {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: manifest: %w", models.ErrIntegrity, err))
+	}
+	return manifest, nil
+}
+
+func (u *PackageManager) writeMetadata(meta models.Metadata) error {
+	return u.writeMetadataAt(fmt.Sprintf("%s/%s", meta.Name, meta.Version), meta)
+}
+
+// writeMetadataAt stores meta as the metadata of versionStatement ("dir/ver").
+func (u *PackageManager) writeMetadataAt(versionStatement string, meta models.Metadata) error {
+	bs, err := json.MarshalIndent(meta, "", "  ")
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
+	return tracerr.Wrap(u.client.WriteMeta(versionStatement, bs))
+}
+
+// readMetadata returns the stored metadata of name@ver, or ErrNotFound for
+// versions published before metadata was recorded.
+func (u *PackageManager) readMetadata(name, ver string) (models.Metadata, error) {
+	return u.readMetadataAt(fmt.Sprintf("%s/%s", name, ver))
+}
+
+// readMetadataAt returns the metadata of versionStatement ("dir/ver").
+func (u *PackageManager) readMetadataAt(versionStatement string) (models.Metadata, error) {
+	meta := models.Metadata{}
+	bs, err := u.client.ReadMeta(versionStatement)
+	if err != nil {
+		return meta, tracerr.Wrap(err)
+	}
+	if err = json.Unmarshal(bs, &meta); err != nil {
+		return meta, tracerr.Wrap(fmt.Errorf("%w: metadata of %s: %w", models.ErrIntegrity, versionStatement, err))
+	}
+	return meta, nil
+}
+
+// handleArchive extracts packageStream into output and describes what was
+// written. File modes, modification times, symlinks and empty directories
+// recorded in the archive are restored. Entries that would land outside
+// output, device files and archives exceeding the extract limits are
+// refused with ErrIntegrity.
+func (u *PackageManager) handleArchive(output string, packageStream models.IArchiveStream) (extraction, error) {
+	ex := extraction{Entries: []string{}}
 	defer packageStream.Close()
 
-	err = fs.WalkDir(fs_, ".", func(path string, d fs.DirEntry, err error) error {
+	if err := os.MkdirAll(output, 0777); err != nil {
+		return ex, tracerr.Wrap(err)
+	}
+	root, err := filepath.EvalSymlinks(output)
+	if err != nil {
+		return ex, tracerr.Wrap(err)
+	}
+
+	ctx := context.Background()
+	extractor, stream, err := openExtractor(ctx, packageStream)
+	if err != nil {
+		return ex, tracerr.Wrap(err)
+	}
+
+	// directory modes and times are applied last, writing their
+	// contents would change them otherwise
+	dirs := make(map[string]archives.FileInfo)
+	dirOrder := make([]string, 0)
+	entries := 0
+	err = extractor.Extract(ctx, stream, func(ctx context.Context, f archives.FileInfo) error {
+		if entries++; entries > u.extractLimits.MaxEntries {
+			return fmt.Errorf("%w: archive has more than %d entries", models.ErrIntegrity, u.extractLimits.MaxEntries)
+		}
+		if f.NameInArchive == models.ManifestName {
+			ex.Manifest, err = u.readManifestEntry(f)
+			return err
+		}
+		name, err := sanitizeArchivePath(f.NameInArchive)
 		if err != nil {
-			return tracerr.Wrap(err)
+			return err
 		}
-		if path == "." {
-			return nil
+		if f.Mode()&(fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe|fs.ModeSocket|fs.ModeIrregular) != 0 {
+			return fmt.Errorf("%w: refusing to extract special file %s (%s)", models.ErrIntegrity, f.NameInArchive, f.Mode())
 		}
-		filepath_ := filepath.Join(output, path)
-		if d.IsDir() {
-			err = os.MkdirAll(filepath_, 0777)
+		ex.Entries = append(ex.Entries, filepath.ToSlash(name))
+
+		if f.IsDir() {
+			dir, err := resolveInside(root, filepath.Join(root, name))
 			if err != nil {
-				return tracerr.Wrap(err)
+				return err
 			}
+			if _, ok := dirs[dir]; !ok {
+				dirOrder = append(dirOrder, dir)
+			}
+			dirs[dir] = f
 			return nil
 		}
-		f, err := os.Create(filepath_)
+		parent, err := resolveInside(root, filepath.Dir(filepath.Join(root, name)))
 		if err != nil {
-			return tracerr.Wrap(err)
+			return err
+		}
+		filepath_ := filepath.Join(parent, filepath.Base(name))
+		if f.Mode()&fs.ModeSymlink != 0 {
+			if err := u.extractSymlink(root, filepath_, f.LinkTarget); err != nil {
+				return err
+			}
+		} else {
+			n, err := u.extractFile(filepath_, f, u.extractLimits.MaxTotalSize-ex.Bytes)
+			ex.Bytes += n
+			if err != nil {
+				return err
+			}
+		}
+		ex.Files++
+		return nil
+	})
+	if err != nil {
+		return ex, tracerr.Wrap(err)
+	}
+	for i := len(dirOrder) - 1; i >= 0; i-- {
+		if err = restoreAttributes(dirOrder[i], dirs[dirOrder[i]].Mode(), dirs[dirOrder[i]].ModTime()); err != nil {
+			return ex, tracerr.Wrap(err)
 		}
-		defer f.Close()
+	}
+	return ex, nil
+}
 
-		archEntry, err := fs_.Open(path)
-		if err != nil {
-			return tracerr.Wrap(err)
+// openExtractor identifies the format of an archive from its content. The
+// returned reader must be used in place of stream.
+func openExtractor(ctx context.Context, stream io.Reader) (archives.Extractor, io.Reader, error) {
+	format, reader, err := archives.Identify(ctx, "", stream)
+	if err != nil {
+		return nil, nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrIntegrity, err))
+	}
+	extractor, ok := format.(archives.Extractor)
+	if !ok {
+		return nil, nil, tracerr.Wrap(fmt.Errorf("%w: %s archives can not be extracted", models.ErrIntegrity, format.Extension()))
+	}
+	return extractor, reader, nil
+}
+
+// extractFile writes the entry to filepath_, replacing whatever is there
+// without following symlinks. At most budget bytes are written, and zip
+// entries are also bounded by their compression ratio.
+func (u *PackageManager) extractFile(filepath_ string, f archives.FileInfo, budget int64) (int64, error) {
+	limit, reason := budget, "total size limit"
+	if header, ok := f.Header.(kzip.FileHeader); ok {
+		ratioLimit := int64(float64(header.CompressedSize64) * u.extractLimits.MaxRatio)
+		if ratioLimit < u.extractLimits.RatioThreshold {
+			ratioLimit = u.extractLimits.RatioThreshold
 		}
-		defer archEntry.Close()
+		if ratioLimit < limit {
+			limit, reason = ratioLimit, "compression ratio limit"
+		}
+	}
+	if err := removeIfSymlink(filepath_); err != nil {
+		return 0, tracerr.Wrap(err)
+	}
 
-		_, err = io.Copy(f, archEntry)
-		if err != nil {
-			return tracerr.Wrap(err)
+	archEntry, err := f.Open()
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	defer archEntry.Close()
+
+	localFile, err := os.OpenFile(filepath_, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.Mode().Perm())
+	if err != nil {
+		return 0, tracerr.Wrap(err)
+	}
+	n, err := io.Copy(localFile, io.LimitReader(archEntry, limit+1))
+	if err == nil && n > limit {
+		err = fmt.Errorf("%w: %s exceeds the %s", models.ErrIntegrity, f.NameInArchive, reason)
+	}
+	if err != nil {
+		localFile.Close()
+		os.Remove(filepath_)
+		return n, tracerr.Wrap(err)
+	}
+	if err = localFile.Close(); err != nil {
+		return n, tracerr.Wrap(err)
+	}
+	return n, tracerr.Wrap(restoreAttributes(filepath_, f.Mode(), f.ModTime()))
+}
+
+// extractSymlink creates the link when its target stays inside root.
+func (u *PackageManager) extractSymlink(root, filepath_, target string) error {
+	if target == "" || filepath.IsAbs(target) || !isWithin(root, filepath.Join(filepath.Dir(filepath_), target)) {
+		return fmt.Errorf("%w: symlink %s -> %s points outside the output directory", models.ErrIntegrity, filepath_, target)
+	}
+	if err := os.Remove(filepath_); err != nil && !os.IsNotExist(err) {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(os.Symlink(target, filepath_))
+}
+
+// sanitizeArchivePath returns the entry name as a local path, refusing
+// absolute names and names that climb out with "..".
+func sanitizeArchivePath(name string) (string, error) {
+	clean := filepath.FromSlash(strings.TrimSuffix(name, "/"))
+	if strings.Contains(name, "\\") || !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("%w: unsafe path %q in archive", models.ErrIntegrity, name)
+	}
+	return filepath.Clean(clean), nil
+}
+
+// resolveInside creates dir and returns its real path, failing when dir or
+// one of its existing parents resolves outside root through a symlink.
+func resolveInside(root, dir string) (string, error) {
+	existing := dir
+	for existing != root {
+		if _, err := os.Lstat(existing); err == nil {
+			break
 		}
-		return nil
-	})
+		existing = filepath.Dir(existing)
+	}
+	if _, err := realPathInside(root, existing); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", tracerr.Wrap(err)
+	}
+	return realPathInside(root, dir)
+}
+
+func realPathInside(root, p string) (string, error) {
+	real, err := filepath.EvalSymlinks(p)
 	if err != nil {
+		return "", tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrIntegrity, err))
+	}
+	if !isWithin(root, real) {
+		return "", fmt.Errorf("%w: %s resolves outside the output directory", models.ErrIntegrity, p)
+	}
+	return real, nil
+}
+
+func isWithin(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+func removeIfSymlink(filepath_ string) error {
+	info, err := os.Lstat(filepath_)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(filepath_)
+}
+
+// restoreAttributes applies the archived permissions, which the umask may have
+// narrowed, and the modification time when the archive recorded one.
+func restoreAttributes(filepath_ string, mode fs.FileMode, modTime time.Time) error {
+	if err := os.Chmod(filepath_, mode.Perm()); err != nil {
 		return tracerr.Wrap(err)
 	}
-	return nil
+	if modTime.IsZero() {
+		return nil
+	}
+	return tracerr.Wrap(os.Chtimes(filepath_, modTime, modTime))
 }
@@ -3,7 +3,9 @@ package internal
 import (
 	"PackageManager/internal/models"
 	"PackageManager/internal/utils"
+	"archive/zip"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,8 +13,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/ztrue/tracerr"
 )
 
@@ -91,6 +96,7 @@ func TestRemoteClient_Create(t *testing.T) {
 	}
 
 	os.Chdir("../")
+	defer os.Chdir("internal")
 
 	defer os.RemoveAll(remoteFsPath)
 	defer os.RemoveAll(outputPath)
@@ -104,60 +110,2062 @@ func TestRemoteClient_Create(t *testing.T) {
 			t.Fatal(tracerr.Sprint(err))
 		}
 
-		err = client.create(models.Create(tt.inputPack), remoteStorageMockFunc_create, "create")
+		results, err := client.create(models.Create(tt.inputPack), remoteStorageMockFunc_create, "create", false)
 		if err != nil {
 			t.Fatal(tracerr.Sprint(err))
 		}
-		for _, p := range tt.inputPack.Packets {
+		if len(results) != len(tt.inputPack.Packets) {
+			t.Fatalf("want %d results, got %d", len(tt.inputPack.Packets), len(results))
+		}
+		for i, p := range tt.inputPack.Packets {
+			if results[i].Package != p.Name || results[i].Version != p.Ver || results[i].Files == 0 ||
+				!strings.HasPrefix(results[i].Digest, "sha256:") {
+				t.Fatalf("unexpected result %+v", results[i])
+			}
 			_, err := os.Stat(fmt.Sprintf("%s/%s/%s.zip", remoteFsPath, p.Name, p.Ver))
 			if err != nil {
 				t.Fatal(err)
 			}
 		}
 
-		err = client.fetch(models.Read(tt.inputUnpack), outputPath, remoteStorageMockFunc_fetch)
+		fetched, err := client.fetch(models.Read(tt.inputUnpack), outputPath, remoteStorageMockFunc_fetch)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		if len(fetched) == 0 || fetched[0].Files == 0 {
+			t.Fatalf("unexpected fetch results %+v", fetched)
+		}
+		for _, f := range tt.wantFiles {
+			_, err := os.Stat(fmt.Sprintf("%s/%s", outputPath, f))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err = client.delete(models.Delete(tt.inputUnpack), 0, nil, remoteStorageMockFunc_remove)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		os.RemoveAll(outputPath)
+		os.Mkdir(outputPath, fs.ModePerm)
+		_, err = client.fetch(models.Read(tt.inputUnpack), outputPath, remoteStorageMockFunc_fetch)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+
+		for _, p := range tt.inputUnpack.Packages {
+			op, needVer := utils.ParseVersion(p.Ver)
+			versions, err := mock.GetVersions(p.Name)
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			for _, version := range versions {
+				haveVer, _, _ := models.SplitArchiveName(version.Name())
+				ok, err := utils.CompareVersions(haveVer, needVer, op)
+				if err != nil {
+					t.Fatal(tracerr.Sprint(err))
+				}
+				if ok {
+					t.Fatal(tracerr.Sprint(fmt.Errorf("version %s not match", version.Name())))
+				}
+			}
+		}
+
+		os.RemoveAll(remoteFsPath)
+		os.RemoveAll(outputPath)
+	}
+}
+
+func TestRemoteClient_Immutability(t *testing.T) {
+	pack := models.Create{
+		Packets: []models.Packets{{
+			Name:    "packet-1",
+			Ver:     "1.0",
+			Targets: []models.Targets{{Path: "test/*", Exclude: "*.ext"}},
+		}},
+	}
+
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	_, err = client.create(pack, remoteStorageMockFunc_create, "create", true)
+	if !errors.Is(err, models.ErrVersionExists) {
+		t.Fatalf("want ErrVersionExists, got %v", err)
+	}
+	var pkgErr *models.PackageError
+	if !errors.As(err, &pkgErr) || pkgErr.Name != "packet-1" || pkgErr.Version != "1.0" {
+		t.Fatalf("want PackageError for packet-1@1.0, got %v", err)
+	}
+	if _, err = os.Stat("packet-1_1.0.zip"); !os.IsNotExist(err) {
+		t.Fatal("local archive must be removed after a failed upload")
+	}
+
+	_, err = client.create(pack, remoteStorageMockFunc_update, "update", false)
+	if !errors.Is(err, models.ErrVersionExists) {
+		t.Fatalf("update without force: want ErrVersionExists, got %v", err)
+	}
+	if _, err = client.create(pack, remoteStorageMockFunc_update, "update", true); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+}
+
+func TestRemoteClient_ExactVersion(t *testing.T) {
+	var tests = []struct {
+		name_    string
+		ver      string
+		wantVers []string
+		wantKept []string
+	}{
+		{name_: "version without operator", ver: "1.0", wantVers: []string{"1.0"}, wantKept: []string{"2.0"}},
+		{name_: "exact version", ver: "=2.0", wantVers: []string{"2.0"}, wantKept: []string{"1.0"}},
+		{name_: "no version", ver: "", wantVers: []string{"1.0", "2.0"}},
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}},
+		{Name: "packet-1", Ver: "2.0", Targets: []models.Targets{{Path: "test/file1"}}},
+	}}
+
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	defer os.RemoveAll(remoteFsPath)
+	defer os.RemoveAll(outputPath)
+
+	for _, tt := range tests {
+		os.Mkdir(remoteFsPath, fs.ModePerm)
+		os.Mkdir(outputPath, fs.ModePerm)
+		client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+
+		refs := []models.Packages{{Name: "packet-1", Ver: tt.ver}}
+		results, err := client.fetch(models.Read{Packages: refs}, outputPath, remoteStorageMockFunc_fetch)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		fetched := make([]string, 0)
+		for _, r := range results {
+			fetched = append(fetched, r.Version)
+		}
+		assert.ElementsMatch(t, tt.wantVers, fetched, tt.name_)
+
+		if results, err = client.delete(models.Delete{Packages: refs}, 0, nil, remoteStorageMockFunc_remove); err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		removed := make([]string, 0)
+		for _, r := range results {
+			removed = append(removed, r.Version)
+		}
+		assert.ElementsMatch(t, tt.wantVers, removed, tt.name_)
+		for _, ver := range tt.wantKept {
+			assert.FileExists(t, filepath.Join(remoteFsPath, "packet-1", ver+".zip"), tt.name_)
+		}
+
+		os.RemoveAll(remoteFsPath)
+		os.RemoveAll(outputPath)
+	}
+}
+
+func TestRemoteClient_Search(t *testing.T) {
+	pack := models.Create{Packets: []models.Packets{}}
+	for _, p := range []struct{ name, ver string }{{"packet-1", "1.10"}, {"packet-1", "1.9"}, {"packet-2", "3.0"}, {"other", "1.0"}} {
+		pack.Packets = append(pack.Packets, models.Packets{
+			Name:    p.name,
+			Ver:     p.ver,
+			Targets: []models.Targets{{Path: "test/*", Exclude: "*.ext"}},
+		})
+	}
+
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	packages, err := client.List()
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if len(packages) != 3 || packages[1].Name != "packet-1" ||
+		packages[1].Versions[0].Version != "1.9" || packages[1].Versions[1].Version != "1.10" {
+		t.Fatalf("unexpected list %+v", packages)
+	}
+
+	packages, err = client.Search("packet-*", ">=1.10")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if len(packages) != 2 || len(packages[0].Versions) != 1 || packages[0].Versions[0].Version != "1.10" ||
+		packages[1].Name != "packet-2" {
+		t.Fatalf("unexpected search %+v", packages)
+	}
+
+	if _, err = client.Search("[", ""); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestRemoteClient_Info(t *testing.T) {
+	deps := []models.Packages{{Name: "packet-2", Ver: ">=3.0"}}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.9", Targets: []models.Targets{{Path: "test/*", Exclude: "*.ext"}}},
+		{Name: "packet-1", Ver: "1.10", Targets: []models.Targets{{Path: "test/file*", Exclude: "*.noext"}}, Dependencies: deps},
+	}}
+
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	results, err := client.create(pack, remoteStorageMockFunc_create, "create", false)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	details, err := client.Info("packet-1", ">=1.0")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if details.Version != "1.10" || details.Digest != results[1].Digest || details.Size != results[1].Bytes {
+		t.Fatalf("unexpected metadata %+v", details.Metadata)
+	}
+	if !assert.Equal(t, deps, details.Dependencies) || details.Manifest == nil ||
+		len(details.Manifest.Files) != len(details.Files) {
+		t.Fatalf("unexpected details %+v", details)
+	}
+	paths := make([]string, 0)
+	for _, f := range details.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.ElementsMatch(t, []string{"test/file1", "test/file2", "test/file3.ext"}, paths)
+
+	// an older version is shown when named without operator
+	if details, err = client.Info("packet-1", "1.9"); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if details.Version != "1.9" || details.Digest != results[0].Digest {
+		t.Fatalf("want 1.9, got %+v", details.Metadata)
+	}
+
+	if _, err = client.Info("packet-1", ">2.0"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+}
+
+func TestRemoteClient_Formats(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	defer os.RemoveAll(remoteFsPath)
+	defer os.RemoveAll(outputPath)
+
+	for _, format := range models.ArchiveFormats {
+		t.Run(format, func(t *testing.T) {
+			os.RemoveAll(remoteFsPath)
+			os.RemoveAll(outputPath)
+			os.Mkdir(remoteFsPath, fs.ModePerm)
+			client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			pack := models.Create{Packets: []models.Packets{{
+				Name:    "packet-1",
+				Ver:     "1.0",
+				Format:  format,
+				Targets: []models.Targets{{Path: "test/file*", Exclude: "*.noext"}},
+			}}}
+			if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			if _, err = os.Stat(fmt.Sprintf("%s/packet-1/1.0.%s", remoteFsPath, format)); err != nil {
+				t.Fatal(err)
+			}
+
+			details, err := client.Info("packet-1", "=1.0")
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			if details.Format != format || details.Manifest == nil || len(details.Manifest.Files) != len(details.Files) {
+				t.Fatalf("unexpected details %+v", details)
+			}
+
+			unpack := models.Read{Packages: []models.Packages{{Name: "packet-1", Ver: "=1.0"}}}
+			results, err := client.fetch(unpack, outputPath, remoteStorageMockFunc_fetch)
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			if len(results) != 1 || results[0].Files != len(details.Files) {
+				t.Fatalf("unexpected fetch results %+v", results)
+			}
+			for _, f := range details.Files {
+				want, _ := os.ReadFile(f.Path)
+				have, err := os.ReadFile(filepath.Join(outputPath, f.Path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, want, have)
+			}
+
+			// overwriting in another format replaces the published archive
+			pack.Packets[0].Format = models.FormatTarGz
+			if format == models.FormatTarGz {
+				pack.Packets[0].Format = models.FormatZip
+			}
+			if _, err = client.create(pack, remoteStorageMockFunc_update, "update", true); err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			versions, err := (&uploaderMock{}).GetVersions("packet-1")
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			if len(versions) != 1 || versions[0].Name() != models.ArchiveName("1.0", pack.Packets[0].Format) {
+				t.Fatalf("unexpected versions after overwrite %v", versions)
+			}
+			if details, err = client.Info("packet-1", "=1.0"); err != nil || details.Format != pack.Packets[0].Format {
+				t.Fatalf("unexpected metadata after overwrite %+v, %v", details.Metadata, err)
+			}
+		})
+	}
+
+	pack := models.Create{Packets: []models.Packets{{Name: "packet-1", Ver: "2.0", Format: "rar"}}}
+	client, _ := NewRemoteClient(context.Background(), &uploaderMock{})
+	if _, err := client.create(pack, remoteStorageMockFunc_create, "create", false); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestRemoteClient_Reproducible(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	defer os.RemoveAll(remoteFsPath)
+
+	build := func(t *testing.T, client *PackageManager, pack models.Create) string {
+		os.RemoveAll(remoteFsPath)
+		os.Mkdir(remoteFsPath, fs.ModePerm)
+		results, err := client.create(pack, remoteStorageMockFunc_create, "create", false)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		return results[0].Digest
+	}
+	touch := func(t *testing.T, modTime time.Time) {
+		for _, f := range []string{"test/file1", "test/file2"} {
+			if err := os.Chtimes(f, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	defer touch(t, time.Now())
+
+	for _, format := range models.ArchiveFormats {
+		t.Run(format, func(t *testing.T) {
+			client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			pack := models.Create{Packets: []models.Packets{{
+				Name:         "packet-1",
+				Ver:          "1.0",
+				Format:       format,
+				Reproducible: true,
+				Targets: []models.Targets{
+					{Path: "test/file2"},
+					{Path: "test/file1"},
+				},
+			}}}
+
+			touch(t, time.Now().Add(-time.Hour))
+			first := build(t, client, pack)
+			touch(t, time.Now())
+			pack.Packets[0].Targets[0], pack.Packets[0].Targets[1] = pack.Packets[0].Targets[1], pack.Packets[0].Targets[0]
+			second := build(t, client, pack)
+			assert.Equal(t, first, second)
+
+			details, err := client.Info("packet-1", "=1.0")
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			paths := make([]string, 0)
+			for _, f := range details.Files {
+				paths = append(paths, f.Path)
+				assert.True(t, f.ModTime.Equal(normalizedModTime), f.Path)
+			}
+			assert.Equal(t, []string{"test/file1", "test/file2"}, paths)
+			assert.True(t, details.Manifest.Created.Equal(normalizedModTime))
+		})
+	}
+
+	t.Run("SOURCE_DATE_EPOCH", func(t *testing.T) {
+		epoch := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+		t.Setenv(utils.SourceDateEpochEnv, fmt.Sprint(epoch.Unix()))
+		client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		pack := models.Create{Packets: []models.Packets{{
+			Name:    "packet-1",
+			Ver:     "1.0",
+			Format:  models.FormatTarGz,
+			Targets: []models.Targets{{Path: "test/file1"}},
+		}}}
+		first := build(t, client, pack)
+		touch(t, time.Now().Add(time.Minute))
+		assert.Equal(t, first, build(t, client, pack))
+
+		details, err := client.Info("packet-1", "=1.0")
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		if len(details.Files) != 1 || !details.Files[0].ModTime.Equal(epoch) || !details.Manifest.Created.Equal(epoch) {
+			t.Fatalf("want entries at %s, got %+v", epoch, details)
+		}
+
+		t.Setenv(utils.SourceDateEpochEnv, "yesterday")
+		if _, err = client.create(pack, remoteStorageMockFunc_create, "create", true); !errors.Is(err, models.ErrInvalidInput) {
+			t.Fatalf("want ErrInvalidInput, got %v", err)
+		}
+	})
+}
+
+func TestRemoteClient_Streaming(t *testing.T) {
+	pack := models.Create{Packets: []models.Packets{{
+		Name:    "packet-1",
+		Ver:     "1.0",
+		Targets: []models.Targets{{Path: "test/*", Exclude: "*.ext"}},
+	}}}
+
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	defer os.RemoveAll(remoteFsPath)
+	cwd, _ := os.ReadDir(".")
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	results, err := client.create(pack, remoteStorageMockFunc_create, "create", false)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	digest, size, err := utils.DigestFile(fmt.Sprintf("%s/packet-1/1.0.zip", remoteFsPath))
+	if err != nil || digest != results[0].Digest || size != results[0].Bytes {
+		t.Fatalf("result %+v does not describe the uploaded archive (%s, %d, %v)", results[0], digest, size, err)
+	}
+	os.RemoveAll(remoteFsPath)
+	after, _ := os.ReadDir(".")
+	if !assert.Equal(t, len(cwd), len(after)) {
+		t.Fatal("create must not write into the working directory")
+	}
+
+	// the archive is still being written when the upload gives up
+	failing := func(r io.Reader, dst string) error {
+		io.CopyN(io.Discard, r, 16)
+		return fmt.Errorf("%w: connection reset", models.ErrTransport)
+	}
+	if _, err = client.create(pack, failing, "create", false); !errors.Is(err, models.ErrTransport) {
+		t.Fatalf("want ErrTransport, got %v", err)
+	}
+	// an upload that stops reading early must not be reported as complete
+	truncating := func(r io.Reader, dst string) error {
+		_, err := io.CopyN(io.Discard, r, 16)
+		return err
+	}
+	if _, err = client.create(pack, truncating, "create", false); err == nil {
+		t.Fatal("want an error for a truncated upload")
+	}
+
+	spoolDir := t.TempDir()
+	ctx := context.WithValue(context.Background(), "spool-dir", spoolDir)
+	client, err = NewRemoteClient(ctx, &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	var spooled int64
+	spooling := func(r io.Reader, dst string) error {
+		f, ok := r.(*os.File)
+		if !ok || filepath.Dir(f.Name()) != spoolDir {
+			return fmt.Errorf("want a file in %s, got %T", spoolDir, r)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		spooled = info.Size()
+		return remoteStorageMockFunc_create(r, dst)
+	}
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	results, err = client.create(pack, spooling, "create", false)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, results[0].Bytes, spooled)
+	if entries, _ := os.ReadDir(spoolDir); len(entries) != 0 {
+		t.Fatalf("spool directory must be cleaned up, found %v", entries)
+	}
+}
+
+func TestRemoteClient_Delta(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	src := t.TempDir()
+	write := func(name, data string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(src, name)), fs.ModePerm)
+		if err := os.WriteFile(filepath.Join(src, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("keep", strings.Repeat("unchanged ", 1000))
+	write("change", "old")
+	write("remove", "stale")
+	write("dir/nested", "nested")
+	pack := models.Create{Packets: []models.Packets{{
+		Name:    "packet-1",
+		Ver:     "1.0",
+		Delta:   true,
+		Targets: []models.Targets{{Path: src, StripPrefix: src}},
+	}}}
+
+	downloads := 0
+	download := func(versionStatement string) (models.IArchiveStream, error) {
+		downloads++
+		return remoteStorageMockFunc_fetch(versionStatement)
+	}
+	ctx := context.WithValue(context.Background(), "cache-dir", t.TempDir())
+	client, err := NewRemoteClient(ctx, &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	// without a lower version the first one is packed in full
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	write("change", "new")
+	write("added", "added")
+	os.Remove(filepath.Join(src, "remove"))
+	pack.Packets[0].Ver = "1.1"
+	results, err := client.create(pack, remoteStorageMockFunc_create, "create", false)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 2, results[0].Files)
+
+	details, err := client.Info("packet-1", "=1.1")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	paths := make([]string, 0)
+	for _, f := range details.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.ElementsMatch(t, []string{"added", "change"}, paths)
+	assert.Equal(t, "1.0", details.Base)
+	assert.Equal(t, "1.0", details.Manifest.Base)
+	assert.Len(t, details.Manifest.Files, 4)
+
+	unpack := models.Read{Packages: []models.Packages{{Name: "packet-1", Ver: "=1.1"}}}
+	for i := 0; i < 2; i++ {
+		output := t.TempDir()
+		fetched, err := client.fetch(unpack, output, download)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		if len(fetched) != 1 || fetched[0].Files != 4 {
+			t.Fatalf("unexpected fetch results %+v", fetched)
+		}
+		for _, name := range []string{"keep", "change", "added", "dir/nested"} {
+			want, _ := os.ReadFile(filepath.Join(src, name))
+			have, err := os.ReadFile(filepath.Join(output, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, string(want), string(have), name)
+		}
+		if _, err = os.Stat(filepath.Join(output, "remove")); !os.IsNotExist(err) {
+			t.Fatal("files removed since the base must not be installed")
+		}
+	}
+	// the second fetch is served from the cache
+	assert.Equal(t, 2, downloads)
+
+	_, err = client.delete(models.Delete{Packages: []models.Packages{{Name: "packet-1", Ver: "=1.0"}}}, 0, nil, remoteStorageMockFunc_remove)
+	if !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput removing a delta base, got %v", err)
+	}
+	removed, err := client.delete(models.Delete{Packages: []models.Packages{{Name: "packet-1", Ver: "<=1.1"}}}, 0, nil, remoteStorageMockFunc_remove)
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("unexpected remove results %+v, %v", removed, err)
+	}
+}
+
+func TestRemoteClient_Sync(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	src := t.TempDir()
+	write := func(root, name, data string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, name)), fs.ModePerm)
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	publish := func(name, ver, root string) {
+		pack := models.Create{Packets: []models.Packets{{
+			Name:    name,
+			Ver:     ver,
+			Targets: []models.Targets{{Path: root, StripPrefix: root}},
+		}}}
+		if _, err := client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+	}
+	write(src, "a", "a")
+	write(src, "b", "b")
+	write(src, "dir/c", "c")
+	publish("packet-1", "1.0", src)
+	write(src, "b", "b2")
+	write(src, "d", "d")
+	os.RemoveAll(filepath.Join(src, "dir"))
+	publish("packet-1", "1.1", src)
+	other := t.TempDir()
+	write(other, "x", "x")
+	publish("packet-2", "1.0", other)
+
+	output := t.TempDir()
+	sync := func(force bool, packages ...models.Packages) ([]models.Result, error) {
+		return client.sync(models.Read{Packages: packages}, output, force, remoteStorageMockFunc_fetch)
+	}
+	assertFiles := func(want map[string]string, gone ...string) {
+		t.Helper()
+		for name, data := range want {
+			have, err := os.ReadFile(filepath.Join(output, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, data, string(have), name)
+		}
+		for _, name := range gone {
+			if _, err := os.Lstat(filepath.Join(output, name)); !os.IsNotExist(err) {
+				t.Fatalf("%s must be removed", name)
+			}
+		}
+	}
+	actions := func(results []models.Result) []string {
+		ret := make([]string, 0)
+		for _, r := range results {
+			ret = append(ret, fmt.Sprintf("%s %s@%s", r.Action, r.Package, r.Version))
+		}
+		return ret
+	}
+
+	results, err := sync(false, models.Packages{Name: "packet-1", Ver: "<1.1"}, models.Packages{Name: "packet-2"})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, []string{"fetch packet-1@1.0", "fetch packet-2@1.0"}, actions(results))
+	assertFiles(map[string]string{"a": "a", "b": "b", "dir/c": "c", "x": "x"})
+	write(output, "untracked", "mine")
+
+	results, err = sync(false, models.Packages{Name: "packet-1", Ver: "<1.1"}, models.Packages{Name: "packet-2"})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, []string{"unchanged packet-1@1.0", "unchanged packet-2@1.0"}, actions(results))
+
+	results, err = sync(false, models.Packages{Name: "packet-1"})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, []string{"fetch packet-1@1.1", "remove packet-2@1.0"}, actions(results))
+	assertFiles(map[string]string{"a": "a", "b": "b2", "d": "d", "untracked": "mine"}, "dir", "x")
+
+	write(output, "a", "changed locally")
+	_, err = sync(false, models.Packages{Name: "packet-1", Ver: "<1.1"})
+	if !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	assertFiles(map[string]string{"a": "changed locally", "b": "b2", "d": "d"})
+
+	if _, err = sync(true, models.Packages{Name: "packet-1", Ver: "<1.1"}); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assertFiles(map[string]string{"a": "a", "b": "b", "dir/c": "c", "untracked": "mine"}, "d")
+
+	db, err := loadInstallDB(output)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if len(db.Packages) != 1 || db.Packages[0].Version != "1.0" || len(db.Packages[0].Files) != 3 {
+		t.Fatalf("unexpected install database %+v", db)
+	}
+}
+
+func TestRemoteClient_InstallDB(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	src := t.TempDir()
+	write := func(root, name, data string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, name)), fs.ModePerm)
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	write(src, "a", "a")
+	write(src, "dir/b", "b")
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: src, StripPrefix: src}}},
+		{Name: "packet-1", Ver: "1.1", Targets: []models.Targets{{Path: src, StripPrefix: src}}},
+	}}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	output := t.TempDir()
+	unpack := models.Read{Packages: []models.Packages{{Name: "packet-1", Dest: "{version}"}}}
+	if _, err = client.fetch(unpack, output, remoteStorageMockFunc_fetch); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	installed, err := client.Installed(output)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 2, len(installed))
+	for _, pkg := range installed {
+		assert.Equal(t, pkg.Version, pkg.Dir)
+		assert.NotEmpty(t, pkg.Digest)
+		assert.Equal(t, 2, len(pkg.Files))
+	}
+
+	if _, err = client.Verify(output, nil); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	write(output, "1.0/a", "changed locally")
+	os.Remove(filepath.Join(output, "1.1", "dir", "b"))
+	checks, err := client.Verify(output, nil)
+	if !errors.Is(err, models.ErrIntegrity) {
+		t.Fatalf("want ErrIntegrity, got %v", err)
+	}
+	assert.Equal(t, []string{"1.0/a"}, checks[0].Modified)
+	assert.Equal(t, []string{"1.1/dir/b"}, checks[1].Missing)
+
+	_, err = client.Uninstall(output, []models.Packages{{Name: "packet-1", Ver: "<1.1"}}, false)
+	if !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	// a version without operator removes that version only
+	results, err := client.Uninstall(output, []models.Packages{{Name: "packet-1", Ver: "1.1"}}, false)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if len(results) != 1 || results[0].Version != "1.1" {
+		t.Fatalf("want only 1.1 uninstalled, got %+v", results)
+	}
+	if _, err = os.Lstat(filepath.Join(output, "1.1")); !os.IsNotExist(err) {
+		t.Fatal("1.1 must be removed")
+	}
+	if installed, err = client.Installed(output); err != nil || len(installed) != 1 || installed[0].Version != "1.0" {
+		t.Fatalf("want 1.0 still installed, got %+v, %v", installed, err)
+	}
+	if _, err = client.Uninstall(output, []models.Packages{{Name: "packet-1"}}, true); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	installed, err = client.Installed(output)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 0, len(installed))
+	if _, err = os.Lstat(filepath.Join(output, "1.0")); !os.IsNotExist(err) {
+		t.Fatal("1.0 must be removed")
+	}
+}
+
+func TestRemoteClient_DryRun(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	src := t.TempDir()
+	os.Mkdir(filepath.Join(src, "dir"), fs.ModePerm)
+	os.WriteFile(filepath.Join(src, "a"), []byte("aaa"), 0644)
+	os.WriteFile(filepath.Join(src, "dir", "b"), []byte("b"), 0644)
+	pack := models.Create{Packets: []models.Packets{{
+		Name:    "packet-1",
+		Ver:     "1.0",
+		Targets: []models.Targets{{Path: src, StripPrefix: src}},
+	}}}
+	archive := filepath.Join(remoteFsPath, "packet-1", "1.0.zip")
+
+	dryRun, err := NewRemoteClient(context.WithValue(context.Background(), "dry-run", true), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	results, err := dryRun.create(pack, remoteStorageMockFunc_create, "create", false)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, models.StatusDryRun, results[0].Status)
+	assert.Equal(t, "packet-1/1.0.zip", results[0].Target)
+	assert.Equal(t, 2, results[0].Files)
+	assert.Equal(t, int64(4), results[0].Bytes)
+	assert.Equal(t, 2, len(results[0].Entries))
+	if _, err = os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatal("a dry run must not upload")
+	}
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	output := t.TempDir()
+	unpack := models.Read{Packages: []models.Packages{{Name: "packet-1"}}}
+	results, err = dryRun.fetch(unpack, output, remoteStorageMockFunc_fetch)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, 2, results[0].Files)
+	assert.NotEmpty(t, results[0].Digest)
+	results, err = dryRun.sync(unpack, output, false, remoteStorageMockFunc_fetch)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "fetch", results[0].Action)
+	if entries, _ := os.ReadDir(output); len(entries) != 0 {
+		t.Fatal("a dry run must not install")
+	}
+
+	results, err = dryRun.delete(models.Delete{Packages: []models.Packages{{Name: "packet-1"}}}, 0, nil, remoteStorageMockFunc_remove)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "packet-1/1.0.zip", results[0].Target)
+	if _, err = os.Stat(archive); err != nil {
+		t.Fatal("a dry run must not remove")
+	}
+}
+
+func TestRemoteClient_Trash(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	publish := func(versions ...string) {
+		pack := models.Create{Packets: []models.Packets{}}
+		for _, ver := range versions {
+			pack.Packets = append(pack.Packets, models.Packets{
+				Name:    "packet-1",
+				Ver:     ver,
+				Targets: []models.Targets{{Path: "test/file1"}},
+			})
+		}
+		if _, err := client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+	}
+	published := func() []string {
+		info, err := client.packageInfo("packet-1", "", utils.ALL)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		ret := make([]string, 0)
+		for _, v := range info.Versions {
+			ret = append(ret, v.Version)
+		}
+		return ret
+	}
+	publish("1.0", "1.1", "2.0")
+	remove := func(ver string, confirmed bool) ([]models.Result, error) {
+		unpack := models.Delete{Packages: []models.Packages{{Name: "packet-1", Ver: ver}}}
+		return client.delete(unpack, time.Hour, func(planned []models.Result) bool { return confirmed }, remoteStorageMockFunc_remove)
+	}
+
+	_, err = remove("<2.0", false)
+	if !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput, got %v", err)
+	}
+	assert.Equal(t, []string{"1.0", "1.1", "2.0"}, published())
+	if _, err = remove("<2.0", true); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, []string{"2.0"}, published())
+	trashed, err := client.Trashed(nil)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 2, len(trashed))
+	assert.False(t, trashed[0].Trashed.IsZero())
+	assert.NotEmpty(t, trashed[0].Digest)
+
+	if _, err = client.Restore([]models.Packages{{Name: "packet-1", Ver: "=1.0"}}); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, []string{"1.0", "2.0"}, published())
+	meta, err := client.readMetadata("packet-1", "1.0")
+	if err != nil || !meta.Trashed.IsZero() || meta.Digest == "" {
+		t.Fatalf("unexpected metadata of a restored version %+v, %v", meta, err)
+	}
+
+	publish("1.1")
+	_, err = client.Restore([]models.Packages{{Name: "packet-1", Ver: "=1.1"}})
+	if !errors.Is(err, models.ErrVersionExists) {
+		t.Fatalf("want ErrVersionExists, got %v", err)
+	}
+	// removing a version again replaces the copy in the trash
+	if _, err = remove("=1.1", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if trashed, _ = client.Trashed([]string{"packet-1"}); len(trashed) != 1 {
+		t.Fatalf("unexpected trash %+v", trashed)
+	}
+
+	// a version without operator restores that version only
+	if _, err = remove("2.0", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	results, err := client.Restore([]models.Packages{{Name: "packet-1", Ver: "1.1"}})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if len(results) != 1 || results[0].Version != "1.1" {
+		t.Fatalf("want only 1.1 restored, got %+v", results)
+	}
+	assert.Equal(t, []string{"1.0", "1.1"}, published())
+
+	// restoring waits for a publisher of the version
+	other, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	unlock, err := other.lock(models.VersionLock("packet-1", "2.0"))
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.Restore([]models.Packages{{Name: "packet-1", Ver: "2.0"}}); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	unlock()
+	if trashed, _ = client.Trashed([]string{"packet-1"}); len(trashed) != 1 || trashed[0].Version != "2.0" {
+		t.Fatalf("unexpected trash %+v", trashed)
+	}
+
+	if err = client.purgeTrash("packet-1", time.Now().Add(2*time.Hour), remoteStorageMockFunc_remove); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if trashed, _ = client.Trashed([]string{"packet-1"}); len(trashed) != 0 {
+		t.Fatalf("expired versions must be purged, got %+v", trashed)
+	}
+}
+
+func TestRemoteClient_GC(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{}}
+	for _, p := range []struct{ name, ver string }{
+		{"packet-1", "0.9"}, {"packet-1", "1.0"}, {"packet-1", "1.1"}, {"packet-1", "1.2"},
+		{"packet-1", "2.0"}, {"packet-1", "3.0"}, {"other", "1.0"},
+	} {
+		pack.Packets = append(pack.Packets, models.Packets{Name: p.name, Ver: p.ver, Targets: []models.Targets{{Path: "test/file1"}}})
+	}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	rewrite := func(ver string, change func(meta *models.Metadata)) {
+		meta, err := client.readMetadata("packet-1", ver)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		change(&meta)
+		if err = client.writeMetadata(meta); err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	for _, ver := range []string{"0.9", "1.0", "1.1", "2.0", "3.0"} {
+		rewrite(ver, func(meta *models.Metadata) { meta.Uploaded = old })
+	}
+	// 1.2 is a delta of 1.0
+	rewrite("1.2", func(meta *models.Metadata) { meta.Base = "1.0" })
+	if _, err = client.Reindex(); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	policy := models.GCPolicy{Rules: []models.RetentionRule{
+		{Package: "packet-*", KeepLatest: 1, KeepDays: 7, Keep: []string{">=2.0"}},
+	}}
+	locked := []models.LockedPackage{{Name: "packet-1", Ver: "1.1"}, {Name: "other", Version: "1.0"}}
+	versions := func(name string) []string {
+		info, err := client.packageInfo(name, "", utils.ALL)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		ret := make([]string, 0)
+		for _, v := range info.Versions {
+			ret = append(ret, v.Version)
+		}
+		return ret
+	}
+
+	client.dryRun = true
+	results, err := client.gc(policy, locked, time.Now(), remoteStorageMockFunc_remove)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if len(results) != 1 || results[0].Version != "0.9" || results[0].Status != models.StatusDryRun || results[0].Bytes == 0 {
+		t.Fatalf("unexpected dry run %+v", results)
+	}
+	assert.Equal(t, 6, len(versions("packet-1")))
+
+	// a version locked by another client is left to the next run
+	client.dryRun = false
+	other, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	unlock, err := other.lock(models.VersionLock("packet-1", "0.9"))
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if results, err = client.gc(policy, locked, time.Now(), remoteStorageMockFunc_remove); err != nil || len(results) != 0 {
+		t.Fatalf("want the locked version skipped, got %+v, %v", results, err)
+	}
+	assert.Equal(t, 6, len(versions("packet-1")))
+	unlock()
+
+	if _, err = client.gc(policy, locked, time.Now(), remoteStorageMockFunc_remove); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, []string{"1.0", "1.1", "1.2", "2.0", "3.0"}, versions("packet-1"))
+	assert.Equal(t, []string{"1.0"}, versions("other"))
+}
+
+func TestRemoteClient_Index(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.10", Targets: []models.Targets{{Path: "test/file1"}},
+			Dependencies: []models.Packages{{Name: "packet-2", Ver: ">=1.0"}}},
+		{Name: "packet-1", Ver: "1.9", Targets: []models.Targets{{Path: "test/file1"}}},
+		{Name: "packet-2", Ver: "1.0", Format: models.FormatTarGz, Targets: []models.Targets{{Path: "test/file2"}}},
+	}}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	stored := func() *models.Index {
+		index, err := client.readIndex()
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		return index
+	}
+	index := stored()
+	assert.Equal(t, 2, len(index.Packages))
+	versions := index.Package("packet-1").Versions
+	assert.Equal(t, "1.9", versions[0].Version)
+	assert.Equal(t, "1.10", versions[1].Version)
+	assert.Equal(t, "1.10.zip", versions[1].File)
+	assert.Equal(t, pack.Packets[0].Dependencies, versions[1].Dependencies)
+	assert.NotEmpty(t, versions[1].Digest)
+	assert.Equal(t, "1.0.tar.gz", index.Package("packet-2").Versions[0].File)
+
+	_, err = client.delete(models.Delete{Packages: []models.Packages{{Name: "packet-2"}}}, 0, nil, remoteStorageMockFunc_remove)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Nil(t, stored().Package("packet-2"))
+
+	// listings come from the index, a version it does not know is not found
+	os.Rename(filepath.Join(remoteFsPath, "packet-1", "1.9.zip"), filepath.Join(remoteFsPath, "packet-1", "1.8.zip"))
+	if _, err = client.Info("packet-1", "=1.8"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	index, err = client.Reindex()
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "1.8.zip", stored().Package("packet-1").Versions[0].File)
+	if _, err = client.Info("packet-1", "=1.8"); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	// a storage without index is listed and gets one with the next change
+	os.Remove(filepath.Join(remoteFsPath, models.IndexName))
+	packages, err := client.List()
+	if err != nil || len(packages) != 1 {
+		t.Fatalf("unexpected packages %+v, %v", packages, err)
+	}
+	pack = models.Create{Packets: []models.Packets{{Name: "packet-3", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}}}}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 2, len(stored().Packages))
+}
+
+func TestRemoteClient_Yank(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}},
+		{Name: "packet-1", Ver: "1.1", Targets: []models.Targets{{Path: "test/file1"}}},
+	}}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.Yank([]models.Packages{{Name: "packet-1", Ver: ">=1.0"}}, true); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput for a range, got %v", err)
+	}
+	if _, err = client.Yank([]models.Packages{{Name: "packet-1", Ver: "2.0"}}, true); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	results, err := client.Yank([]models.Packages{{Name: "packet-1", Ver: "1.1"}}, true)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "yank", results[0].Action)
+	meta, err := client.readMetadata("packet-1", "1.1")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.True(t, meta.Yanked)
+	index, err := client.readIndex()
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.True(t, index.Package("packet-1").Versions[1].Yanked)
+
+	// ranges skip the yanked version, an exact pin still installs it
+	details, err := client.Info("packet-1", ">=1.0")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "1.0", details.Version)
+	output := t.TempDir()
+	fetch := func(ver string) []models.Result {
+		results, err := client.fetch(models.Read{Packages: []models.Packages{{Name: "packet-1", Ver: ver, Dest: "{version}"}}},
+			output, remoteStorageMockFunc_fetch)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		return results
+	}
+	results = fetch(">=1.0")
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "1.0", results[0].Version)
+	results = fetch("=1.1")
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "1.1", results[0].Version)
+	// a version without operator pins the one it names, as lockfiles do
+	results = fetch("1.1")
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "1.1", results[0].Version)
+
+	if results, err = client.Yank([]models.Packages{{Name: "packet-1", Ver: "=1.1"}}, false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "unyank", results[0].Action)
+	if results, err = client.Yank([]models.Packages{{Name: "packet-1", Ver: "1.1"}}, false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "unchanged", results[0].Action)
+
+	// yanking waits for a publisher of the version
+	other, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	unlock, err := other.lock(models.VersionLock("packet-1", "1.0"))
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.Yank([]models.Packages{{Name: "packet-1", Ver: "1.0"}}, true); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	unlock()
+	if details, err = client.Info("packet-1", ">=1.0"); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "1.1", details.Version)
+}
+
+func TestRemoteClient_Locks(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}}}}
+
+	// another publisher holds the version, nothing is published meanwhile
+	other, err := NewRemoteClient(context.WithValue(context.Background(), "lock-ttl", time.Hour), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	unlock, err := other.lock(models.VersionLock("packet-1", "1.0"))
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	assert.NoFileExists(t, filepath.Join(remoteFsPath, "packet-1", "1.0.zip"))
+	locks, err := client.Locks()
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 1, len(locks))
+	assert.Equal(t, "packet-1@1.0", locks[0].Name)
+	assert.Equal(t, os.Getpid(), locks[0].Pid)
+	assert.False(t, locks[0].Stale(time.Now()))
+
+	unlock()
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if locks, err = client.Locks(); err != nil || len(locks) != 0 {
+		t.Fatalf("want the locks released, got %+v, %v", locks, err)
+	}
+
+	// an expired lock is broken, the holder does not release the new one
+	stale, err := NewRemoteClient(context.WithValue(context.Background(), "lock-ttl", time.Nanosecond), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	staleUnlock, err := stale.lock(models.IndexLock)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = client.Yank([]models.Packages{{Name: "packet-1", Ver: "1.0"}}, true); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	unlock, err = other.lock(models.IndexLock)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	staleUnlock()
+	if locks, err = client.Locks(); err != nil || len(locks) != 1 {
+		t.Fatalf("want the index lock kept, got %+v, %v", locks, err)
+	}
+
+	if _, err = client.BreakLocks([]string{"packet-2@1.0"}, false); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	broken, err := client.BreakLocks(nil, true)
+	if err != nil || len(broken) != 0 {
+		t.Fatalf("want no stale locks, got %+v, %v", broken, err)
+	}
+	if broken, err = client.BreakLocks([]string{models.IndexLock}, false); err != nil || len(broken) != 1 {
+		t.Fatalf("want the index lock broken, got %+v, %v", broken, err)
+	}
+	if locks, err = client.Locks(); err != nil || len(locks) != 0 {
+		t.Fatalf("want no locks, got %+v, %v", locks, err)
+	}
+}
+
+func TestRemoteClient_Tags(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}, Tags: []string{"stable"}},
+		{Name: "packet-1", Ver: "2.0", Targets: []models.Targets{{Path: "test/file1"}}, Tags: []string{"beta"}},
+	}}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	tags, err := client.Tags("packet-1")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, models.Tags{"stable": "1.0", "beta": "2.0"}, tags)
+	packages, err := client.List()
+	if err != nil || len(packages[0].Versions) != 2 {
+		t.Fatalf("want the tags not listed as a version, got %+v, %v", packages, err)
+	}
+
+	// tags resolve before version matching, to an exact pin
+	details, err := client.Info("packet-1", "@stable")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "1.0", details.Version)
+	results, err := client.fetch(models.Read{Packages: []models.Packages{{Name: "packet-1", Ver: "@beta"}}}, t.TempDir(),
+		remoteStorageMockFunc_fetch)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "2.0", results[0].Version)
+	if _, err = client.Info("packet-1", "@latest"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+
+	if _, err = client.Tag("packet-1", "3.0", []string{"latest"}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if _, err = client.Tag("packet-1", "2.0", []string{"1.x"}); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput, got %v", err)
+	}
+	if results, err = client.Tag("packet-1", "2.0", []string{"stable", "beta"}); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "tag", results[0].Action)
+	assert.Equal(t, "unchanged", results[1].Action)
+	if details, err = client.Info("packet-1", "@stable"); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "2.0", details.Version)
+
+	// gc keeps tagged versions
+	policy := models.GCPolicy{Rules: []models.RetentionRule{{Package: "*"}}}
+	if results, err = client.gc(policy, nil, time.Now(), remoteStorageMockFunc_remove); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "1.0", results[0].Version)
+
+	if _, err = client.Untag("packet-1", []string{"beta", "rc"}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	if results, err = client.Untag("packet-1", []string{"beta"}); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "2.0", results[0].Version)
+	if tags, err = client.Tags("packet-1"); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, models.Tags{"stable": "2.0"}, tags)
+}
+
+func TestRemoteClient_Promote(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	dev, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	prodRoot := t.TempDir()
+	prod, err := NewRemoteClient(context.Background(), &uploaderMock{root: prodRoot})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}, Tags: []string{"stable"}},
+		{Name: "packet-1", Ver: "1.1", Targets: []models.Targets{{Path: "test/file1"}, {Path: "test/file2"}}, Delta: true},
+	}}
+	if _, err = dev.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	// signatures put next to an archive are copied with it
+	signature := filepath.Join("packet-1", "1.0.zip.sig")
+	if err = os.WriteFile(filepath.Join(remoteFsPath, signature), []byte("signature"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a delta needs its base on the target
+	if _, err = dev.Promote(prod, []models.Packages{{Name: "packet-1", Ver: "1.1"}}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	results, err := dev.Promote(prod, []models.Packages{{Name: "packet-1", Ver: "@stable"}, {Name: "packet-1", Ver: "=1.1"}})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 2, len(results))
+	for _, result := range results {
+		meta, err := dev.readMetadata("packet-1", result.Version)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		promoted, err := prod.readMetadata("packet-1", result.Version)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		assert.Equal(t, meta, promoted)
+		assert.Equal(t, meta.Digest, result.Digest)
+		digest, _, err := utils.DigestFile(filepath.Join(prodRoot, "packet-1", result.Version+".zip"))
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		assert.Equal(t, meta.Digest, digest)
+	}
+	if signed, err := os.ReadFile(filepath.Join(prodRoot, signature)); err != nil || string(signed) != "signature" {
+		t.Fatalf("want the signature promoted, got %q, %v", signed, err)
+	}
+	assert.NoFileExists(t, filepath.Join(prodRoot, "packet-1", "1.1.zip.sig"))
+	packages, err := prod.List()
+	if err != nil || len(packages) != 1 || len(packages[0].Versions) != 2 {
+		t.Fatalf("want both versions indexed on the target, got %+v, %v", packages, err)
+	}
+
+	if results, err = dev.Promote(prod, []models.Packages{{Name: "packet-1", Ver: "1.0"}}); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "unchanged", results[0].Action)
+
+	// a source archive that no longer matches its digest is not promoted
+	pack = models.Create{Packets: []models.Packets{{Name: "packet-2", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}}}}
+	if _, err = dev.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if err = os.WriteFile(filepath.Join(remoteFsPath, "packet-2", "1.0.zip"), []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dev.Promote(prod, []models.Packages{{Name: "packet-2", Ver: "1.0"}}); !errors.Is(err, models.ErrIntegrity) {
+		t.Fatalf("want ErrIntegrity, got %v", err)
+	}
+	assert.NoFileExists(t, filepath.Join(prodRoot, "packet-2", "1.0.zip"))
+}
+
+func TestRemoteClient_Mirror(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	prod, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	backupRoot := t.TempDir()
+	backup, err := NewRemoteClient(context.WithValue(context.Background(), "workerNum", 2), &uploaderMock{root: backupRoot})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}, Tags: []string{"stable"}},
+		{Name: "packet-1", Ver: "1.1", Targets: []models.Targets{{Path: "test/file1"}, {Path: "test/file2"}}, Delta: true},
+		{Name: "packet-2", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1"}}},
+	}}
+	if _, err = prod.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	stateFile := filepath.Join(t.TempDir(), "mirror.json")
+	opts := models.MirrorOptions{Pattern: "packet-1", StateFile: stateFile, Source: "prod", Destination: "backup"}
+
+	// a delta is not mirrored without its base
+	if _, err = prod.Mirror(backup, models.MirrorOptions{Pattern: "packet-1", Constraint: ">1.0"}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("want ErrNotFound, got %v", err)
+	}
+	results, err := prod.Mirror(backup, opts)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, []string{"mirror", "mirror", "tag"}, []string{results[0].Action, results[1].Action, results[2].Action})
+	for _, result := range results[:2] {
+		meta, err := prod.readMetadata("packet-1", result.Version)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		mirrored, err := backup.readMetadata("packet-1", result.Version)
+		if err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		assert.Equal(t, meta, mirrored)
+	}
+	tags, err := backup.Tags("packet-1")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, models.Tags{"stable": "1.0"}, tags)
+	assert.NoFileExists(t, stateFile)
+
+	// nothing changed, nothing is copied
+	if results, err = prod.Mirror(backup, opts); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 2, len(results))
+	for _, result := range results {
+		assert.Equal(t, "unchanged", result.Action)
+	}
+
+	// a yanked version only has its metadata mirrored
+	if _, err = prod.Yank([]models.Packages{{Name: "packet-1", Ver: "1.0"}}, true); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if results, err = prod.Mirror(backup, opts); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "mirror", results[0].Action)
+	assert.Equal(t, int64(0), results[0].Bytes)
+	if yanked, err := backup.yanked("packet-1", "1.0"); err != nil || !yanked {
+		t.Fatalf("want 1.0 yanked on the destination, got %v, %v", yanked, err)
+	}
+
+	// a run resumed from its state skips the versions it copied
+	meta, err := prod.readMetadata("packet-2", "1.0")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	state := models.MirrorState{Source: "prod", Destination: "backup", Done: map[string]string{"packet-2@1.0": meta.Digest}}
+	bs, _ := json.Marshal(state)
+	if err = os.WriteFile(stateFile, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.Pattern = "*"
+	if results, err = prod.Mirror(backup, opts); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, "packet-2", results[2].Package)
+	assert.Equal(t, "unchanged", results[2].Action)
+	assert.NoFileExists(t, filepath.Join(backupRoot, "packet-2", "1.0.zip"))
+
+	// a state of another mirror is refused
+	state.Destination = "staging"
+	bs, _ = json.Marshal(state)
+	if err = os.WriteFile(stateFile, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = prod.Mirror(backup, opts); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	os.Remove(stateFile)
+
+	// a version published again is copied over the mirrored one
+	if results, err = prod.Mirror(backup, opts); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "mirror", results[2].Action)
+	pack = models.Create{Packets: []models.Packets{{Name: "packet-2", Ver: "1.0", Targets: []models.Targets{{Path: "test/file2"}}}}}
+	if _, err = prod.create(pack, remoteStorageMockFunc_update, "update", true); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if results, err = prod.Mirror(backup, opts); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "mirror", results[2].Action)
+	if meta, err = prod.readMetadata("packet-2", "1.0"); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	digest, _, err := utils.DigestFile(filepath.Join(backupRoot, "packet-2", "1.0.zip"))
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, meta.Digest, digest)
+
+	// a version published again in another format replaces the mirrored
+	// archive, with its signature
+	pack.Packets[0].Format = models.FormatTarGz
+	if _, err = prod.create(pack, remoteStorageMockFunc_update, "update", true); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	signature := filepath.Join("packet-2", "1.0.tar.gz.sig")
+	if err = os.WriteFile(filepath.Join(remoteFsPath, signature), []byte("signature"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if results, err = prod.Mirror(backup, opts); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, "mirror", results[2].Action)
+	assert.NoFileExists(t, filepath.Join(backupRoot, "packet-2", "1.0.zip"))
+	assert.FileExists(t, filepath.Join(backupRoot, signature))
+	if meta, err = prod.readMetadata("packet-2", "1.0"); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	mirrored, err := backup.readMetadata("packet-2", "1.0")
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, meta, mirrored)
+}
+
+// breakRaceMock runs before right before the next lock is renamed, as if
+// another client was faster breaking the same stale lock.
+type breakRaceMock struct {
+	uploaderMock
+	before func()
+}
+
+func (m *breakRaceMock) RenameLock(file, to string) error {
+	if before := m.before; before != nil {
+		m.before = nil
+		before()
+	}
+	return m.uploaderMock.RenameLock(file, to)
+}
+
+func TestRemoteClient_BreakStaleLock(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+
+	crashed, err := NewRemoteClient(context.WithValue(context.Background(), "lock-ttl", time.Nanosecond), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	if _, err = crashed.lock(models.IndexLock); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	ctx := context.WithValue(context.Background(), "lock-ttl", time.Hour)
+
+	// the other client breaks the stale lock and takes it before this one
+	// renames it, the lock it took is put back
+	race := &breakRaceMock{}
+	client, err := NewRemoteClient(ctx, race)
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	other, err := NewRemoteClient(ctx, &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	var otherUnlock func()
+	race.before = func() {
+		if otherUnlock, err = other.lock(models.IndexLock); err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+	}
+	if _, err = client.lock(models.IndexLock); !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("want ErrConflict, got %v", err)
+	}
+	locks, err := client.Locks()
+	if err != nil || len(locks) != 1 || locks[0].Stale(time.Now()) {
+		t.Fatalf("want the lock of the other client kept, got %+v, %v", locks, err)
+	}
+	otherUnlock()
+	entries, err := os.ReadDir(filepath.Join(remoteFsPath, models.LockDir))
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("want the lock released and nothing left behind, got %v, %v", entries, err)
+	}
+
+	// two clients racing to break the same stale lock never hold it both
+	if _, err = crashed.lock(models.IndexLock); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	ctx = context.WithValue(ctx, "lock-wait", time.Minute)
+	clients := make([]*PackageManager, 0, 2)
+	for range 2 {
+		c, err := NewRemoteClient(ctx, &uploaderMock{})
 		if err != nil {
 			t.Fatal(tracerr.Sprint(err))
 		}
-		for _, f := range tt.wantFiles {
-			_, err := os.Stat(fmt.Sprintf("%s/%s", outputPath, f))
+		clients = append(clients, c)
+	}
+	var mu sync.Mutex
+	holders, most := 0, 0
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, len(clients))
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			unlock, err := c.lock(models.IndexLock)
 			if err != nil {
-				t.Fatal(err)
+				errs <- err
+				return
+			}
+			mu.Lock()
+			holders++
+			most = max(most, holders)
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	assert.Equal(t, 1, most)
+	if locks, err = client.Locks(); err != nil || len(locks) != 0 {
+		t.Fatalf("want no locks, got %+v, %v", locks, err)
+	}
+}
+
+func TestRemoteClient_CollectFiles(t *testing.T) {
+	var tests = []struct {
+		name_     string
+		targets   []models.Targets
+		pmignore  string
+		wantFiles []string
+	}{
+		{
+			name_:   "recursive double star with excludes",
+			targets: []models.Targets{{Path: "test/**", Excludes: []string{"*.noext", "*.ext"}}},
+			wantFiles: []string{
+				"test/file1", "test/file2",
+				"test/test2/file1", "test/test2/file2",
+			},
+		},
+		{
+			name_:     "directory match is walked, subtree excluded",
+			targets:   []models.Targets{{Path: "test", Exclude: "test/test2/", Excludes: []string{"file[3-5]*"}}},
+			wantFiles: []string{"test/file1", "test/file2"},
+		},
+		{
+			name_:     "last matching pattern wins",
+			targets:   []models.Targets{{Path: "test/test2/*", Excludes: []string{"file*", "!*.ext"}}},
+			wantFiles: []string{"test/test2/file3.ext"},
+		},
+		{
+			name_:     "pmignore applies before target excludes",
+			targets:   []models.Targets{{Path: "test/**/*.ext", Excludes: []string{"!test/file3.ext"}}},
+			pmignore:  "# packaging\n*.ext\n",
+			wantFiles: []string{"test/file3.ext"},
+		},
+		{
+			name_:     "overlapping targets pack a file once",
+			targets:   []models.Targets{{Path: "test/file1"}, {Path: "test/file*", Exclude: "*.*"}},
+			wantFiles: []string{"test/file1", "test/file2"},
+		},
+		{
+			name_:     "strip prefix and dest",
+			targets:   []models.Targets{{Path: "test/test2", Exclude: "*.*", StripPrefix: "test/", Dest: "share/pkg"}},
+			wantFiles: []string{"share/pkg/test2/file1", "share/pkg/test2/file2"},
+		},
+		{
+			name_: "strip prefix keeps file names",
+			targets: []models.Targets{
+				{Path: "test/file1", StripPrefix: "test"},
+				{Path: "test/test2/file2", StripPrefix: "test/test2", Dest: "bin"},
+			},
+			wantFiles: []string{"file1", "bin/file2"},
+		},
+	}
+
+	os.Chdir("../")
+	defer os.Chdir("internal")
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name_, func(t *testing.T) {
+			if tt.pmignore != "" {
+				os.WriteFile(utils.IgnoreFileName, []byte(tt.pmignore), 0644)
+				defer os.Remove(utils.IgnoreFileName)
+			}
+			files, err := client.collectFiles(tt.targets)
+			if err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+			names := make([]string, 0, len(files))
+			for _, f := range files {
+				names = append(names, f.Name)
 			}
+			assert.ElementsMatch(t, tt.wantFiles, names)
+		})
+	}
+
+	var failing = []struct {
+		name_   string
+		targets []models.Targets
+	}{
+		{name_: "outside strip prefix", targets: []models.Targets{{Path: "test/file1", StripPrefix: "other"}}},
+		{name_: "dest escapes", targets: []models.Targets{{Path: "test/file1", Dest: "../up"}}},
+		{name_: "same archive path", targets: []models.Targets{{Path: "test/file1", StripPrefix: "test"}, {Path: "test/test2/file1", StripPrefix: "test/test2"}}},
+	}
+	for _, tt := range failing {
+		if _, err := client.collectFiles(tt.targets); !errors.Is(err, models.ErrInvalidInput) {
+			t.Fatalf("%s: want ErrInvalidInput, got %v", tt.name_, err)
 		}
+	}
+}
 
-		err = client.delete(models.Delete(tt.inputUnpack), remoteStorageMockFunc_remove)
-		if err != nil {
-			t.Fatal(tracerr.Sprint(err))
+func TestRemoteClient_InstallDir(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+	defer os.RemoveAll(outputPath)
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "packet-1", Ver: "1.0", Targets: []models.Targets{{Path: "test/file1", StripPrefix: "test", Dest: "bin"}}},
+		{Name: "packet-1", Ver: "2.0", Targets: []models.Targets{{Path: "test/file2", StripPrefix: "test", Dest: "bin"}}},
+	}}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	unpack := models.Read{Packages: []models.Packages{{Name: "packet-1", Dest: "{name}/{version}"}}}
+	if _, err = client.fetch(unpack, outputPath, remoteStorageMockFunc_fetch); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	for _, f := range []string{"packet-1/1.0/bin/file1", "packet-1/2.0/bin/file2"} {
+		if _, err = os.Stat(filepath.Join(outputPath, f)); err != nil {
+			t.Fatal(err)
 		}
-		os.RemoveAll(outputPath)
-		os.Mkdir(outputPath, fs.ModePerm)
-		err = client.fetch(models.Read(tt.inputUnpack), outputPath, remoteStorageMockFunc_fetch)
+	}
+
+	unpack.Packages[0].Dest = "../{name}"
+	if _, err = client.fetch(unpack, outputPath, remoteStorageMockFunc_fetch); !errors.Is(err, models.ErrInvalidInput) {
+		t.Fatalf("want ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestRemoteClient_PreserveAttributes(t *testing.T) {
+	os.Chdir("../")
+	defer os.Chdir("internal")
+	os.Mkdir(remoteFsPath, fs.ModePerm)
+	defer os.RemoveAll(remoteFsPath)
+	defer os.RemoveAll(outputPath)
+
+	srcPath := "attributes"
+	modTime := time.Date(2020, time.May, 4, 3, 2, 1, 0, time.UTC)
+	os.MkdirAll(filepath.Join(srcPath, "empty"), 0755)
+	defer os.RemoveAll(srcPath)
+	os.WriteFile(filepath.Join(srcPath, "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(srcPath, "data"), []byte("data"), 0600)
+	os.Chtimes(filepath.Join(srcPath, "data"), modTime, modTime)
+	if err := os.Symlink("data", filepath.Join(srcPath, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewRemoteClient(context.Background(), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	pack := models.Create{Packets: []models.Packets{
+		{Name: "attributes", Ver: "1.0", Targets: []models.Targets{{Path: srcPath + "/**"}}},
+		{Name: "attributes", Ver: "2.0", Targets: []models.Targets{{Path: srcPath + "/**"}}, Normalize: true},
+	}}
+	if _, err = client.create(pack, remoteStorageMockFunc_create, "create", false); err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+
+	var tests = []struct {
+		ver      string
+		dataMode fs.FileMode
+		dataTime time.Time
+	}{
+		{ver: "=1.0", dataMode: 0600, dataTime: modTime},
+		{ver: "=2.0", dataMode: 0644, dataTime: time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ver, func(t *testing.T) {
+			os.RemoveAll(outputPath)
+			os.Mkdir(outputPath, fs.ModePerm)
+			unpack := models.Read{Packages: []models.Packages{{Name: "attributes", Ver: tt.ver}}}
+			if _, err := client.fetch(unpack, outputPath, remoteStorageMockFunc_fetch); err != nil {
+				t.Fatal(tracerr.Sprint(err))
+			}
+
+			script, err := os.Stat(filepath.Join(outputPath, srcPath, "run.sh"))
+			if err != nil || script.Mode().Perm()&0100 == 0 {
+				t.Fatalf("executable bit lost: %v %v", script, err)
+			}
+			data, err := os.Stat(filepath.Join(outputPath, srcPath, "data"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.dataMode, data.Mode().Perm())
+			assert.True(t, tt.dataTime.Equal(data.ModTime()), data.ModTime())
+			target, err := os.Readlink(filepath.Join(outputPath, srcPath, "link"))
+			if err != nil || target != "data" {
+				t.Fatalf("symlink not restored: %s %v", target, err)
+			}
+			empty, err := os.Stat(filepath.Join(outputPath, srcPath, "empty"))
+			if err != nil || !empty.IsDir() {
+				t.Fatalf("empty directory not restored: %v", err)
+			}
+		})
+	}
+}
+
+type zipEntry struct {
+	name string
+	mode fs.FileMode
+	data []byte
+}
+
+func writeZip(t *testing.T, path string, entries []zipEntry) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode == 0 {
+			e.mode = 0644
+		}
+		header.SetMode(e.mode)
+		entry, err := w.CreateHeader(header)
 		if err != nil {
-			t.Fatal(tracerr.Sprint(err))
+			t.Fatal(err)
+		}
+		if _, err = entry.Write(e.data); err != nil {
+			t.Fatal(err)
 		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
 
-		for _, p := range tt.inputUnpack.Packages {
-			op, needVer := utils.ParseVersion(p.Ver)
-			versions, err := mock.GetVersions(p.Name)
+func TestRemoteClient_MaliciousArchives(t *testing.T) {
+	limits := models.ExtractLimits{MaxEntries: 4, MaxTotalSize: 4096, MaxRatio: 10, RatioThreshold: 1024}
+	var tests = []struct {
+		name_   string
+		entries []zipEntry
+	}{
+		{name_: "parent traversal", entries: []zipEntry{{name: "../evil", data: []byte("x")}}},
+		{name_: "nested traversal", entries: []zipEntry{{name: "a/../../evil", data: []byte("x")}}},
+		{name_: "absolute path", entries: []zipEntry{{name: "/tmp/evil", data: []byte("x")}}},
+		{name_: "backslash traversal", entries: []zipEntry{{name: "..\\evil", data: []byte("x")}}},
+		{name_: "absolute symlink", entries: []zipEntry{{name: "link", mode: fs.ModeSymlink | 0777, data: []byte("/etc")}}},
+		{name_: "relative symlink escape", entries: []zipEntry{{name: "link", mode: fs.ModeSymlink | 0777, data: []byte("../..")}}},
+		{name_: "symlink chain escape", entries: []zipEntry{
+			{name: "a", mode: fs.ModeSymlink | 0777, data: []byte(".")},
+			{name: "a/b", mode: fs.ModeSymlink | 0777, data: []byte("..")},
+			{name: "a/b/evil", data: []byte("x")},
+		}},
+		{name_: "device file", entries: []zipEntry{{name: "dev", mode: fs.ModeDevice | fs.ModeCharDevice | 0666}}},
+		{name_: "named pipe", entries: []zipEntry{{name: "fifo", mode: fs.ModeNamedPipe | 0666}}},
+		{name_: "too many entries", entries: []zipEntry{
+			{name: "1"}, {name: "2"}, {name: "3"}, {name: "4"}, {name: "5"},
+		}},
+		{name_: "total size", entries: []zipEntry{
+			{name: "1", data: []byte(strings.Repeat("0123456789abcdef", 200))},
+			{name: "2", data: []byte(strings.Repeat("fedcba9876543210", 200))},
+		}},
+		{name_: "compression ratio", entries: []zipEntry{{name: "bomb", data: make([]byte, 4000)}}},
+	}
+
+	client, err := NewRemoteClient(context.WithValue(context.Background(), "extract-limits", limits), &uploaderMock{})
+	if err != nil {
+		t.Fatal(tracerr.Sprint(err))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name_, func(t *testing.T) {
+			dir := t.TempDir()
+			archivePath := filepath.Join(dir, "malicious.zip")
+			output := filepath.Join(dir, "nested", "output")
+			writeZip(t, archivePath, tt.entries)
+
+			stream, err := os.Open(archivePath)
 			if err != nil {
-				t.Fatal(tracerr.Sprint(err))
+				t.Fatal(err)
 			}
-			for _, version := range versions {
-				haveVer := strings.Replace(version.Name(), filepath.Ext(version.Name()), "", -1)
-				ok, err := utils.CompareVersions(haveVer, needVer, op)
-				if err != nil {
-					t.Fatal(tracerr.Sprint(err))
-				}
-				if ok {
-					t.Fatal(tracerr.Sprint(fmt.Errorf("version %s not match", version.Name())))
+			_, err = client.handleArchive(output, stream)
+			if !errors.Is(err, models.ErrIntegrity) {
+				t.Fatalf("want ErrIntegrity, got %v", err)
+			}
+			for _, escaped := range []string{filepath.Join(dir, "evil"), filepath.Join(dir, "nested", "evil")} {
+				if _, err = os.Lstat(escaped); !os.IsNotExist(err) {
+					t.Fatalf("%s was written outside the output directory", escaped)
 				}
 			}
-		}
-
-		os.RemoveAll(remoteFsPath)
-		os.RemoveAll(outputPath)
+		})
 	}
+
+	t.Run("symlinks inside output are allowed", func(t *testing.T) {
+		dir := t.TempDir()
+		archivePath := filepath.Join(dir, "safe.zip")
+		writeZip(t, archivePath, []zipEntry{
+			{name: "bin/tool", mode: 0755, data: []byte("#!/bin/sh\n")},
+			{name: "current", mode: fs.ModeSymlink | 0777, data: []byte("bin")},
+			{name: "current/extra", data: []byte("x")},
+		})
+		stream, err := os.Open(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = client.handleArchive(filepath.Join(dir, "output"), stream); err != nil {
+			t.Fatal(tracerr.Sprint(err))
+		}
+		if _, err = os.Stat(filepath.Join(dir, "output", "bin", "extra")); err != nil {
+			t.Fatal(err)
+		}
+	})
 }
 
 func getFiles(wantFiles []models.Targets) ([]string, error) {
@@ -186,21 +2194,157 @@ func getFiles(wantFiles []models.Targets) ([]string, error) {
 	return ret, nil
 }
 
-type uploaderMock struct{}
+// uploaderMock keeps the storage in root, u.dir() if it is empty.
+type uploaderMock struct {
+	root string
+}
+
+func (u uploaderMock) dir() string {
+	if u.root == "" {
+		return remoteFsPath
+	}
+	return u.root
+}
 
 func (u uploaderMock) Remove(versionStatement string) error {
-	//	mock
+	err := os.RemoveAll(fmt.Sprintf("%s/%s", u.dir(), versionStatement))
+	if err != nil {
+		return err
+	}
+	ver, _, _ := models.SplitArchiveName(filepath.Base(versionStatement))
+	os.Remove(u.metaPath(filepath.Join(filepath.Dir(versionStatement), ver)))
 	return nil
 }
 
+func (u uploaderMock) Move(from, to string) error {
+	fromPath, toPath := fmt.Sprintf("%s/%s", u.dir(), from), fmt.Sprintf("%s/%s", u.dir(), to)
+	if _, err := os.Stat(toPath); err == nil {
+		return fmt.Errorf("%w: %s", models.ErrVersionExists, to)
+	}
+	os.MkdirAll(filepath.Dir(toPath), fs.ModePerm)
+	if err := os.Rename(fromPath, toPath); err != nil {
+		return tracerr.Wrap(err)
+	}
+	fromVer, _, _ := models.SplitArchiveName(filepath.Base(from))
+	toVer, _, _ := models.SplitArchiveName(filepath.Base(to))
+	toMeta := u.metaPath(filepath.Join(filepath.Dir(to), toVer))
+	os.MkdirAll(filepath.Dir(toMeta), fs.ModePerm)
+	err := os.Rename(u.metaPath(filepath.Join(filepath.Dir(from), fromVer)), toMeta)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
 func (u uploaderMock) Download(versionStatement string) (models.IArchiveStream, error) {
-	//	mock
-	return nil, nil
+	srcFile, err := os.Open(fmt.Sprintf("%s/%s", u.dir(), versionStatement))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrNotFound, err))
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	return srcFile, nil
+}
+
+func (u uploaderMock) WriteMeta(versionStatement string, meta []byte) error {
+	metaPath := u.metaPath(versionStatement)
+	os.MkdirAll(filepath.Dir(metaPath), fs.ModePerm)
+	return os.WriteFile(metaPath, meta, 0644)
+}
+
+func (u uploaderMock) ReadMeta(versionStatement string) ([]byte, error) {
+	meta, err := os.ReadFile(u.metaPath(versionStatement))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrNotFound, err))
+	}
+	return meta, err
+}
+
+func (u uploaderMock) WriteIndex(index []byte) error {
+	os.MkdirAll(u.dir(), fs.ModePerm)
+	return os.WriteFile(filepath.Join(u.dir(), models.IndexName), index, 0644)
+}
+
+func (u uploaderMock) ReadIndex() ([]byte, error) {
+	index, err := os.ReadFile(filepath.Join(u.dir(), models.IndexName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrNotFound, err))
+	}
+	return index, err
+}
+
+func (u uploaderMock) CreateLock(file string, lock []byte) error {
+	os.MkdirAll(filepath.Join(u.dir(), models.LockDir), fs.ModePerm)
+	f, err := os.OpenFile(filepath.Join(u.dir(), models.LockDir, file), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w: %s is locked", models.ErrConflict, file)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(lock)
+	return err
+}
+
+func (u uploaderMock) ReadLock(file string) ([]byte, error) {
+	lock, err := os.ReadFile(filepath.Join(u.dir(), models.LockDir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrNotFound, err))
+	}
+	return lock, err
+}
+
+func (u uploaderMock) RemoveLock(file string) error {
+	err := os.Remove(filepath.Join(u.dir(), models.LockDir, file))
+	if errors.Is(err, os.ErrNotExist) {
+		return tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrNotFound, err))
+	}
+	return err
+}
+
+func (u uploaderMock) RenameLock(file, to string) error {
+	err := os.Rename(filepath.Join(u.dir(), models.LockDir, file), filepath.Join(u.dir(), models.LockDir, to))
+	if errors.Is(err, os.ErrNotExist) {
+		return tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrNotFound, err))
+	}
+	return err
+}
+
+func (u uploaderMock) GetLocks() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(u.dir(), models.LockDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return []os.FileInfo{}, nil
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	ret := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		ret = append(ret, fi)
+	}
+	return ret, nil
+}
+
+func (u uploaderMock) metaPath(versionStatement string) string {
+	return fmt.Sprintf("%s/%s/.meta/%s.json", u.dir(), filepath.Dir(versionStatement), filepath.Base(versionStatement))
+}
+
+func remoteMetaPath(versionStatement string) string {
+	return uploaderMock{}.metaPath(versionStatement)
 }
 
 func (u uploaderMock) GetVersions(versionStatement string) ([]os.FileInfo, error) {
-	packagePath := fmt.Sprintf("%s/%s", remoteFsPath, versionStatement)
+	packagePath := fmt.Sprintf("%s/%s", u.dir(), versionStatement)
 	entries, err := os.ReadDir(packagePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %w", models.ErrNotFound, err))
+	}
 	if err != nil {
 		return nil, tracerr.Wrap(err)
 	}
@@ -210,7 +2354,28 @@ func (u uploaderMock) GetVersions(versionStatement string) ([]os.FileInfo, error
 		if err != nil {
 			return nil, tracerr.Wrap(err)
 		}
-		ret = append(ret, fi)
+		if !fi.IsDir() {
+			ret = append(ret, fi)
+		}
+	}
+
+	return ret, nil
+}
+
+func (u uploaderMock) GetPackages() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(u.dir())
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	ret := make([]os.FileInfo, 0)
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		if fi.IsDir() {
+			ret = append(ret, fi)
+		}
 	}
 
 	return ret, nil
@@ -221,14 +2386,24 @@ func (u uploaderMock) GetStoragePath() string {
 	return "remote"
 }
 
-func (u uploaderMock) Upload(r io.ReadWriter, dst string) error {
-	//	mock
-	return nil
+func (u uploaderMock) Upload(r io.Reader, dst string) error {
+	versionStatement := fmt.Sprintf("%s/%s", u.dir(), dst)
+	if _, err := os.Stat(versionStatement); err == nil {
+		return fmt.Errorf("%w: %s", models.ErrVersionExists, versionStatement)
+	}
+	return u.Update(r, dst)
 }
 
-func (u uploaderMock) Update(r io.ReadWriter, dst string) error {
-	//	mock
-	return nil
+func (u uploaderMock) Update(r io.Reader, dst string) error {
+	versionStatement := fmt.Sprintf("%s/%s", u.dir(), dst)
+	os.MkdirAll(filepath.Dir(versionStatement), fs.ModePerm)
+	f, err := os.Create(versionStatement)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
 }
 
 func (u uploaderMock) Close() error {
@@ -236,11 +2411,11 @@ func (u uploaderMock) Close() error {
 	return nil
 }
 
-func remoteStorageMockFunc_create(streamFrom io.ReadWriter, versionStatement string) error {
-	versionStatement = fmt.Sprintf("%s/%s.zip", remoteFsPath, versionStatement)
+func remoteStorageMockFunc_create(streamFrom io.Reader, versionStatement string) error {
+	versionStatement = fmt.Sprintf("%s/%s", remoteFsPath, versionStatement)
 	_, err := os.Stat(versionStatement)
 	if err == nil {
-		return errors.New("file already exists")
+		return fmt.Errorf("%w: %s", models.ErrVersionExists, versionStatement)
 	}
 
 	os.MkdirAll(filepath.Dir(versionStatement), fs.ModePerm)
@@ -256,10 +2431,10 @@ func remoteStorageMockFunc_create(streamFrom io.ReadWriter, versionStatement str
 	return nil
 }
 
-func remoteStorageMockFunc_update(r io.ReadWriter, versionStatement string) error {
-	versionStatement = fmt.Sprintf("%s/%s.zip", remoteFsPath, versionStatement)
+func remoteStorageMockFunc_update(r io.Reader, versionStatement string) error {
+	versionStatement = fmt.Sprintf("%s/%s", remoteFsPath, versionStatement)
 	os.MkdirAll(filepath.Dir(versionStatement), fs.ModePerm)
-	f, err := os.OpenFile(versionStatement, os.O_TRUNC|os.O_WRONLY, 0755)
+	f, err := os.OpenFile(versionStatement, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
 	if err != nil {
 		return err
 	}
@@ -285,6 +2460,8 @@ func remoteStorageMockFunc_remove(versionStatement string) error {
 	if err != nil {
 		return err
 	}
+	ver, _, _ := models.SplitArchiveName(filepath.Base(versionStatement))
+	os.Remove(remoteMetaPath(filepath.Join(filepath.Dir(versionStatement), ver)))
 
 	return nil
 }
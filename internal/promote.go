@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// Promote copies the exact versions of refs from the storage of u to the
+// one of to as they are, archive, detached signatures and metadata, without
+// packing them again. The copy is checked against the digest recorded when
+// the version was published, on both ends. A delta version needs its base
+// promoted first.
+func (u *PackageManager) Promote(to *PackageManager, refs []models.Packages) ([]models.Result, error) {
+	u.resetIndex()
+	to.resetIndex()
+	results := make([]models.Result, 0, len(refs))
+	for _, ref := range refs {
+		started := time.Now()
+		ref, err := u.resolveTag(ref)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("promote", ref.Name, ref.Ver, err))
+		}
+		op, ver := utils.ParseVersionRef(ref.Ver)
+		if op != utils.EQUAL {
+			return results, tracerr.Wrap(models.NewPackageError("promote", ref.Name, ref.Ver,
+				fmt.Errorf("%w: an exact version is needed", models.ErrInvalidInput)))
+		}
+		version, err := u.publishedVersion(ref.Name, ver)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("promote", ref.Name, ver, err))
+		}
+		if version == nil {
+			return results, tracerr.Wrap(models.NewPackageError("promote", ref.Name, ver, models.ErrNotFound))
+		}
+		meta, err := u.readMetadata(ref.Name, ver)
+		if errors.Is(err, models.ErrNotFound) {
+			_, format, _ := models.SplitArchiveName(version.Name())
+			meta = models.Metadata{Name: ref.Name, Version: ver, Format: format, Size: version.Size(), Uploaded: version.ModTime()}
+		} else if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("promote", ref.Name, ver, err))
+		}
+		result, err := u.promote(to, version, meta, started)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("promote", ref.Name, ver, err))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// promote copies the archive version of meta to to, holding its lock there.
+func (u *PackageManager) promote(to *PackageManager, version os.FileInfo, meta models.Metadata,
+	started time.Time) (models.Result, error) {
+	result := models.NewResult("promote", meta.Name, meta.Version, started)
+	result.Bytes = version.Size()
+	result.Digest = meta.Digest
+	result.Target = path.Join(meta.Name, version.Name())
+
+	if meta.Base != "" {
+		if err := u.checkPromotedBase(to, meta); err != nil {
+			return result, tracerr.Wrap(err)
+		}
+	}
+	if !u.dryRun {
+		unlock, err := to.lock(models.VersionLock(meta.Name, meta.Version))
+		if err != nil {
+			return result, tracerr.Wrap(err)
+		}
+		defer unlock()
+		to.resetIndex()
+	}
+	existing, err := to.publishedVersion(meta.Name, meta.Version)
+	if err != nil {
+		return result, tracerr.Wrap(err)
+	}
+	if existing != nil {
+		promoted, err := to.readMetadata(meta.Name, meta.Version)
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			return result, tracerr.Wrap(err)
+		}
+		if meta.Digest != "" && promoted.Digest == meta.Digest {
+			result.Action = "unchanged"
+			return result, nil
+		}
+		return result, tracerr.Wrap(fmt.Errorf("%w: with another digest", models.ErrVersionExists))
+	}
+	if u.dryRun {
+		result.Status = models.StatusDryRun
+		return result, nil
+	}
+
+	log.Printf("package: promoting %s@%s", meta.Name, meta.Version)
+	meta, err = u.copyVersion(to, result.Target, meta, to.client.Upload)
+	if err != nil {
+		return result, tracerr.Wrap(err)
+	}
+	err = to.updateIndex(func(index *models.Index) {
+		index.Put(meta.Name, models.IndexVersionOf(meta, version.Name()))
+	})
+	if err != nil {
+		return result, tracerr.Wrap(err)
+	}
+	result.Digest = meta.Digest
+	result.DurationMs = time.Since(started).Milliseconds()
+	return result, nil
+}
+
+// copyVersion copies the archive target of meta and its detached signatures
+// to the storage of to through f and writes its metadata there, once the copy
+// matches the recorded digest. The index of to is left alone. It returns meta
+// as written, with the digest read for versions published without one.
+func (u *PackageManager) copyVersion(to *PackageManager, target string, meta models.Metadata,
+	f func(r io.Reader, dst string) error) (models.Metadata, error) {
+	stream, err := u.client.Download(target)
+	if err != nil {
+		return meta, tracerr.Wrap(err)
+	}
+	defer stream.Close()
+	copied := utils.NewDigestWriter()
+	if err = f(io.TeeReader(stream, copied), target); err != nil {
+		return meta, tracerr.Wrap(err)
+	}
+	if meta.Digest == "" {
+		meta.Digest = copied.Digest()
+	}
+	err = to.checkPromoted(target, meta, copied)
+	if err == nil {
+		err = u.copySignatures(to, target)
+	}
+	if err != nil {
+		if rmErr := to.client.Remove(target); rmErr != nil {
+			log.Printf("package: removing the failed copy of %s@%s: %v", meta.Name, meta.Version, rmErr)
+		}
+		return meta, tracerr.Wrap(err)
+	}
+	meta.Size = copied.Size()
+	return meta, tracerr.Wrap(to.writeMetadata(meta))
+}
+
+// checkPromoted checks what was read from the source and what landed on
+// the storage of u against the recorded digest.
+func (u *PackageManager) checkPromoted(target string, meta models.Metadata, copied *utils.DigestWriter) error {
+	if copied.Digest() != meta.Digest {
+		return tracerr.Wrap(fmt.Errorf("%w: source archive has digest %s, published with %s",
+			models.ErrIntegrity, copied.Digest(), meta.Digest))
+	}
+	stream, err := u.client.Download(target)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer stream.Close()
+	digest, _, err := utils.DigestReader(stream)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if digest != meta.Digest {
+		return tracerr.Wrap(fmt.Errorf("%w: copy has digest %s, published with %s", models.ErrIntegrity, digest, meta.Digest))
+	}
+	return nil
+}
+
+// copySignatures copies the detached signatures of the archive target that
+// exist on the storage of u to the one of to. Packages are not signed here,
+// signatures other tools put next to the archive travel with it as they are.
+func (u *PackageManager) copySignatures(to *PackageManager, target string) error {
+	for _, ext := range models.SignatureExts {
+		signature := target + "." + ext
+		stream, err := u.client.Download(signature)
+		if errors.Is(err, models.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		err = to.client.Update(stream, signature)
+		stream.Close()
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// checkPromotedBase checks the delta base of meta was promoted to to, as
+// the same archive.
+func (u *PackageManager) checkPromotedBase(to *PackageManager, meta models.Metadata) error {
+	published, err := to.publishedArchive(meta.Name, meta.Base)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if published == "" {
+		return tracerr.Wrap(fmt.Errorf("%w: delta base %s is not promoted yet", models.ErrNotFound, meta.Base))
+	}
+	base, err := u.readMetadata(meta.Name, meta.Base)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	promoted, err := to.readMetadata(meta.Name, meta.Base)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return tracerr.Wrap(err)
+	}
+	if promoted.Digest != base.Digest {
+		return tracerr.Wrap(fmt.Errorf("%w: delta base %s differs from the promoted one", models.ErrConflict, meta.Base))
+	}
+	return nil
+}
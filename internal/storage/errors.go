@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"PackageManager/internal/models"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// classifyError wraps a native ssh/sftp error with the matching models
+// sentinel, keeping the original error in the chain for errors.Is/As.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	kind := errorKind(err)
+	if kind == nil || errors.Is(err, kind) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+func errorKind(err error) error {
+	var statusErr *sftp.StatusError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return models.ErrNotFound
+	case errors.Is(err, os.ErrPermission):
+		return models.ErrPermission
+	case errors.As(err, &statusErr):
+		switch statusErr.FxCode() {
+		case sftp.ErrSSHFxNoSuchFile:
+			return models.ErrNotFound
+		case sftp.ErrSSHFxPermissionDenied:
+			return models.ErrPermission
+		case sftp.ErrSSHFxNoConnection, sftp.ErrSSHFxConnectionLost:
+			return models.ErrTransport
+		}
+	case errors.Is(err, sftp.ErrSSHFxNoConnection), errors.Is(err, sftp.ErrSSHFxConnectionLost),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &netErr):
+		return models.ErrTransport
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		return models.ErrAuth
+	}
+	return nil
+}
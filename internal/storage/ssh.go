@@ -3,13 +3,16 @@ package storage
 import (
 	"PackageManager/internal/configs"
 	"PackageManager/internal/models"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/sftp"
@@ -26,13 +29,21 @@ type SshClient struct {
 
 const _max_packet_size = 1 << 15
 const _max_pub_key_size = 1 << 15
+const _max_meta_size = 1 << 20
+const _max_index_size = 1 << 28
+
+// _meta_dir holds the metadata sidecars inside every package directory.
+const _meta_dir = ".meta"
+
+// _partial_ext marks uploads in progress, see writePacket.
+const _partial_ext = ".partial"
 
 func NewSshClient(ctx context.Context) (*SshClient, error) {
 	var err error
 
 	sshConfig, ok := ctx.Value("ssh-config").(*configs.SSHConfig)
 	if !ok {
-		return nil, tracerr.New("SSH client config not found in context")
+		return nil, tracerr.Wrap(fmt.Errorf("%w: SSH client config not found in context", models.ErrInvalidInput))
 	}
 
 	auth := ssh.Password(sshConfig.Password)
@@ -43,7 +54,7 @@ func NewSshClient(ctx context.Context) (*SshClient, error) {
 			return nil, tracerr.Wrap(err)
 		}
 		if stat.Size() > _max_pub_key_size {
-			return nil, tracerr.New("private key file too large")
+			return nil, tracerr.Wrap(fmt.Errorf("%w: private key file too large", models.ErrInvalidInput))
 		}
 
 		key, err := os.ReadFile(sshConfig.PrivateKeyFile)
@@ -52,7 +63,7 @@ func NewSshClient(ctx context.Context) (*SshClient, error) {
 		}
 		signer, err := ssh.ParsePrivateKey(key)
 		if err != nil {
-			return nil, tracerr.New(fmt.Sprintf("ssh parse private key: %s", err.Error()))
+			return nil, tracerr.Wrap(fmt.Errorf("%w: ssh parse private key: %w", models.ErrAuth, err))
 		}
 		auth = ssh.PublicKeys(signer)
 	}
@@ -82,51 +93,71 @@ func NewSshClient(ctx context.Context) (*SshClient, error) {
 	return sshClient, nil
 }
 
-func (s *SshClient) Upload(streamFrom io.ReadWriter, versionStatement string) error {
-	versionStatement = s.setExt(s.setPrefix(versionStatement))
+func (s *SshClient) Upload(streamFrom io.Reader, versionStatement string) error {
+	versionStatement = s.setPrefix(versionStatement)
 
 	isExist, err := s.checkPacketIfExist(versionStatement)
 	if err != nil {
 		return tracerr.Wrap(err)
 	}
 	if isExist {
-		return tracerr.New("file already exists")
-	}
-
-	streamTo, err := s.createPacketStream(versionStatement)
-	if err != nil {
-		return tracerr.Wrap(err)
+		return tracerr.Wrap(fmt.Errorf("%w: %s", models.ErrVersionExists, versionStatement))
 	}
 
-	return s.copyPacket(streamFrom, streamTo)
+	return s.writePacket(streamFrom, versionStatement, false)
 }
 
-func (s *SshClient) Update(streamFrom io.ReadWriter, versionStatement string) error {
-	versionStatement = s.setExt(s.setPrefix(versionStatement))
-
-	streamTo, err := s.createPacketStream(versionStatement)
-	if err != nil {
-		return tracerr.Wrap(err)
-	}
+func (s *SshClient) Update(streamFrom io.Reader, versionStatement string) error {
+	versionStatement = s.setPrefix(versionStatement)
 
-	return s.copyPacket(streamFrom, streamTo)
+	return s.writePacket(streamFrom, versionStatement, true)
 }
 
 func (s *SshClient) Remove(versionStatement string) error {
+	metaPath := s.archiveMetaPath(versionStatement)
 	versionStatement = s.setPrefix(versionStatement)
 	err := s.session.Remove(versionStatement)
 	if err != nil {
+		return tracerr.Wrap(classifyError(err))
+	}
+	err = classifyError(s.session.Remove(metaPath))
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
 		return tracerr.Wrap(err)
 	}
 
 	return nil
 }
 
+// Move renames a version ("name/ver.format") and its metadata sidecar to
+// another path below the storage path. An existing version is never
+// replaced, a stale sidecar at the destination is.
+func (s *SshClient) Move(from, to string) error {
+	fromMeta, toMeta := s.archiveMetaPath(from), s.archiveMetaPath(to)
+	from, to = s.setPrefix(from), s.setPrefix(to)
+	if err := s.session.MkdirAll(filepath.Dir(to)); err != nil {
+		return tracerr.Wrap(classifyError(err))
+	}
+	if err := s.session.Rename(from, to); err != nil {
+		if isExist, _ := s.checkPacketIfExist(to); isExist {
+			return tracerr.Wrap(fmt.Errorf("%w: %s", models.ErrVersionExists, to))
+		}
+		return tracerr.Wrap(classifyError(err))
+	}
+
+	if isExist, err := s.checkPacketIfExist(fromMeta); err != nil || !isExist {
+		return tracerr.Wrap(err)
+	}
+	if err := s.session.MkdirAll(filepath.Dir(toMeta)); err != nil {
+		return tracerr.Wrap(classifyError(err))
+	}
+	return tracerr.Wrap(classifyError(s.session.PosixRename(fromMeta, toMeta)))
+}
+
 func (s *SshClient) Download(versionStatement string) (models.IArchiveStream, error) {
 	versionStatement = s.setPrefix(versionStatement)
 	srcFile, err := s.session.OpenFile(versionStatement, os.O_RDONLY)
 	if err != nil {
-		return nil, tracerr.Wrap(err)
+		return nil, tracerr.Wrap(classifyError(err))
 	}
 
 	return srcFile, nil
@@ -135,9 +166,146 @@ func (s *SshClient) Download(versionStatement string) (models.IArchiveStream, er
 func (s *SshClient) GetVersions(packageName string) ([]os.FileInfo, error) {
 	entries, err := s.session.ReadDir(s.setPrefix(packageName))
 	if err != nil {
-		return nil, tracerr.Wrap(err)
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	versions := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			versions = append(versions, entry)
+		}
+	}
+	return versions, nil
+}
+
+// WriteMeta stores the metadata sidecar of a version ("name/ver").
+func (s *SshClient) WriteMeta(versionStatement string, meta []byte) error {
+	streamTo, err := s.createPacketStream(s.metaPath(versionStatement))
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer streamTo.Close()
+	if _, err = streamTo.Write(meta); err != nil {
+		return tracerr.Wrap(classifyError(err))
+	}
+	return nil
+}
+
+// ReadMeta returns the metadata sidecar of a version ("name/ver").
+func (s *SshClient) ReadMeta(versionStatement string) ([]byte, error) {
+	srcFile, err := s.session.Open(s.metaPath(versionStatement))
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	defer srcFile.Close()
+	meta, err := io.ReadAll(io.LimitReader(srcFile, _max_meta_size))
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	return meta, nil
+}
+
+// WriteIndex replaces the index at the storage root, readers never see a
+// partially written one.
+func (s *SshClient) WriteIndex(index []byte) error {
+	return s.writePacket(bytes.NewReader(index), s.setPrefix(models.IndexName), true)
+}
+
+// ReadIndex returns the index at the storage root.
+func (s *SshClient) ReadIndex() ([]byte, error) {
+	srcFile, err := s.session.Open(s.setPrefix(models.IndexName))
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	defer srcFile.Close()
+	index, err := io.ReadAll(io.LimitReader(srcFile, _max_index_size))
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	return index, nil
+}
+
+// CreateLock creates the lock file in models.LockDir with exclusive-create
+// semantics, it fails with ErrConflict while another holder has it.
+func (s *SshClient) CreateLock(file string, lock []byte) error {
+	lockPath := s.setPrefix(filepath.Join(models.LockDir, file))
+	if err := s.session.MkdirAll(filepath.Dir(lockPath)); err != nil {
+		return tracerr.Wrap(classifyError(err))
+	}
+	streamTo, err := s.session.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		// SFTP v3 servers report an existing file as a generic failure
+		if isExist, _ := s.checkPacketIfExist(lockPath); isExist {
+			return tracerr.Wrap(fmt.Errorf("%w: %s is locked", models.ErrConflict, file))
+		}
+		return tracerr.Wrap(classifyError(err))
+	}
+	_, err = streamTo.Write(lock)
+	if closeErr := streamTo.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		s.session.Remove(lockPath)
+		return tracerr.Wrap(classifyError(err))
+	}
+	return nil
+}
+
+// ReadLock returns the lock file in models.LockDir.
+func (s *SshClient) ReadLock(file string) ([]byte, error) {
+	srcFile, err := s.session.Open(s.setPrefix(filepath.Join(models.LockDir, file)))
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	defer srcFile.Close()
+	lock, err := io.ReadAll(io.LimitReader(srcFile, _max_meta_size))
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	return lock, nil
+}
+
+// RemoveLock removes the lock file in models.LockDir.
+func (s *SshClient) RemoveLock(file string) error {
+	return tracerr.Wrap(classifyError(s.session.Remove(s.setPrefix(filepath.Join(models.LockDir, file)))))
+}
+
+// RenameLock renames the lock file in models.LockDir to a name not in use.
+func (s *SshClient) RenameLock(file, to string) error {
+	from := s.setPrefix(filepath.Join(models.LockDir, file))
+	return tracerr.Wrap(classifyError(s.session.Rename(from, s.setPrefix(filepath.Join(models.LockDir, to)))))
+}
+
+// GetLocks lists the lock files in models.LockDir.
+func (s *SshClient) GetLocks() ([]os.FileInfo, error) {
+	entries, err := s.session.ReadDir(s.setPrefix(models.LockDir))
+	if errors.Is(classifyError(err), models.ErrNotFound) {
+		return []os.FileInfo{}, nil
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	locks := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			locks = append(locks, entry)
+		}
 	}
-	return entries, nil
+	return locks, nil
+}
+
+// GetPackages lists the package directories under the storage path.
+func (s *SshClient) GetPackages() ([]os.FileInfo, error) {
+	entries, err := s.session.ReadDir(s.getStoragePath())
+	if err != nil {
+		return nil, tracerr.Wrap(classifyError(err))
+	}
+	packages := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			packages = append(packages, entry)
+		}
+	}
+	return packages, nil
 }
 
 func (s *SshClient) Close() error {
@@ -148,7 +316,7 @@ func (s *SshClient) Close() error {
 func (s *SshClient) establishConnection() (*ssh.Client, error) {
 	sshConn, err := ssh.Dial("tcp", net.JoinHostPort(s.sshConfig.Host, strconv.Itoa(s.sshConfig.Port)), s.clientCfg)
 	if err != nil {
-		return nil, tracerr.Wrap(err)
+		return nil, tracerr.Wrap(classifyError(err))
 	}
 	return sshConn, nil
 }
@@ -157,7 +325,7 @@ func (s *SshClient) createSession(conn *ssh.Client) (*sftp.Client, error) {
 	// open an SFTP session over an existing ssh connection.
 	sftp, err := sftp.NewClient(conn, sftp.MaxPacket(_max_packet_size))
 	if err != nil {
-		return nil, tracerr.Wrap(err)
+		return nil, tracerr.Wrap(classifyError(err))
 	}
 	return sftp, nil
 }
@@ -166,7 +334,10 @@ func (s *SshClient) checkPacketIfExist(filePath string) (bool, error) {
 	var err error
 	_, err = s.session.Stat(filePath)
 	if err != nil {
-		if err == os.ErrNotExist {
+		// sftp reports a missing file either as os.ErrNotExist or as a raw
+		// SSH_FX_NO_SUCH_FILE status, depending on the server.
+		err = classifyError(err)
+		if errors.Is(err, models.ErrNotFound) {
 			return false, nil
 		}
 		return false, tracerr.Wrap(err)
@@ -179,27 +350,53 @@ func (s *SshClient) getStoragePath() string {
 	return s.sshConfig.SshStoragePath
 }
 
-func (s *SshClient) copyPacket(streamFrom io.ReadWriter, streamTo *sftp.File) error {
-	// write to file
-	defer streamTo.Close()
-	if _, err := io.CopyN(streamTo, streamFrom, _max_packet_size); err != nil {
-		if err == io.EOF {
-			return nil
-		}
+// writePacket uploads the stream into a hidden partial file next to fullPath
+// and renames it into place once complete, so a failed or interrupted upload
+// never shows up as a version. Without overwrite the rename fails if another
+// upload published the version meanwhile.
+func (s *SshClient) writePacket(streamFrom io.Reader, fullPath string, overwrite bool) error {
+	partialPath := filepath.Join(filepath.Dir(fullPath), fmt.Sprintf(".%s%s", filepath.Base(fullPath), _partial_ext))
+	streamTo, err := s.createPacketStream(partialPath)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	if err = s.copyPacket(streamFrom, streamTo); err != nil {
+		s.session.Remove(partialPath)
 		return tracerr.Wrap(err)
 	}
+
+	if overwrite {
+		err = s.session.PosixRename(partialPath, fullPath)
+	} else {
+		err = s.session.Rename(partialPath, fullPath)
+	}
+	if err != nil {
+		s.session.Remove(partialPath)
+		if isExist, _ := s.checkPacketIfExist(fullPath); isExist && !overwrite {
+			return tracerr.Wrap(fmt.Errorf("%w: %s", models.ErrVersionExists, fullPath))
+		}
+		return tracerr.Wrap(classifyError(err))
+	}
 	return nil
 }
 
+func (s *SshClient) copyPacket(streamFrom io.Reader, streamTo *sftp.File) error {
+	if _, err := io.Copy(streamTo, streamFrom); err != nil {
+		streamTo.Close()
+		return tracerr.Wrap(classifyError(err))
+	}
+	return tracerr.Wrap(classifyError(streamTo.Close()))
+}
+
 func (s *SshClient) createPacketStream(fullPath string) (*sftp.File, error) {
 	// Create the destination file
 	err := s.session.MkdirAll(filepath.Dir(fullPath))
 	if err != nil {
-		return nil, tracerr.Wrap(err)
+		return nil, tracerr.Wrap(classifyError(err))
 	}
 	streamTo, err := s.session.Create(fullPath)
 	if err != nil {
-		return nil, tracerr.Wrap(err)
+		return nil, tracerr.Wrap(classifyError(err))
 	}
 
 	return streamTo, nil
@@ -209,6 +406,12 @@ func (s *SshClient) setPrefix(input string) string {
 	return fmt.Sprintf("%s/%s", s.getStoragePath(), input)
 }
 
-func (s *SshClient) setExt(input string) string {
-	return fmt.Sprintf("%s.%s", input, "zip")
+// archiveMetaPath returns the metadata sidecar of an archive ("name/ver.format").
+func (s *SshClient) archiveMetaPath(versionStatement string) string {
+	ver, _, _ := models.SplitArchiveName(filepath.Base(versionStatement))
+	return s.metaPath(filepath.Join(filepath.Dir(versionStatement), ver))
+}
+
+func (s *SshClient) metaPath(versionStatement string) string {
+	return s.setPrefix(fmt.Sprintf("%s/%s/%s.json", filepath.Dir(versionStatement), _meta_dir, filepath.Base(versionStatement)))
 }
@@ -2,14 +2,18 @@ package storage
 
 import (
 	"PackageManager/internal/configs"
+	"PackageManager/internal/models"
 	"archive/zip"
 	"context"
+	"errors"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/joho/godotenv"
+	"github.com/pkg/sftp"
 	"github.com/spf13/viper"
 	"github.com/ztrue/tracerr"
 )
@@ -123,3 +127,30 @@ func createArchiveWithTestFile(path string, data []byte) error {
 	testZip.Close()
 	return nil
 }
+
+func TestClassifyError(t *testing.T) {
+	var tests = []struct {
+		name_ string
+		input error
+		want  error
+	}{
+		{name_: "not exist", input: &os.PathError{Op: "stat", Path: "x", Err: os.ErrNotExist}, want: models.ErrNotFound},
+		{name_: "permission", input: os.ErrPermission, want: models.ErrPermission},
+		{name_: "connection lost", input: sftp.ErrSSHFxConnectionLost, want: models.ErrTransport},
+		{name_: "dial", input: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("refused")}, want: models.ErrTransport},
+		{name_: "auth", input: errors.New("ssh: handshake failed: ssh: unable to authenticate"), want: models.ErrAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name_, func(t *testing.T) {
+			err := classifyError(tt.input)
+			if !errors.Is(err, tt.want) || !errors.Is(err, tt.input) {
+				t.Fatalf("%v: want %v", err, tt.want)
+			}
+		})
+	}
+
+	unknown := errors.New("unknown")
+	if classifyError(unknown) != unknown {
+		t.Fatal("unknown errors must be returned unchanged")
+	}
+}
@@ -0,0 +1,360 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// Sync makes output hold exactly the packages of unpack: the highest matching
+// version of each that is not yanked is installed, and the files of replaced versions and of
+// packages no longer listed are removed. Installed files modified locally
+// are neither overwritten nor removed unless force is set.
+func (u *PackageManager) Sync(unpack models.Read, output string, force bool) ([]models.Result, error) {
+	return u.sync(unpack, output, force, u.client.Download)
+}
+
+// syncTarget is the version a package is synced to.
+type syncTarget struct {
+	pkg        models.Packages
+	version    models.VersionInfo
+	digest     string
+	installDir string
+	dir        string
+}
+
+func (u *PackageManager) sync(unpack models.Read, output string, force bool,
+	f func(versionStatement string) (models.IArchiveStream, error)) ([]models.Result, error) {
+	u.resetIndex()
+	results := make([]models.Result, 0, len(unpack.Packages))
+	db, err := loadInstallDB(output)
+	if err != nil {
+		return results, tracerr.Wrap(err)
+	}
+
+	targets := make([]syncTarget, 0, len(unpack.Packages))
+	wanted := make(map[string]bool)
+	for _, p := range unpack.Packages {
+		target, err := u.syncTarget(p, output)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("fetch", p.Name, p.Ver, err))
+		}
+		targets = append(targets, target)
+		wanted[p.Name] = true
+	}
+
+	// check every package about to change before touching any of them
+	install := make([]syncTarget, 0, len(targets))
+	conflicts := make([]string, 0)
+	for _, target := range targets {
+		installs := db.Installs(target.pkg.Name)
+		if len(installs) == 0 {
+			install = append(install, target)
+			continue
+		}
+		changed, clean := make([]string, 0), true
+		for i := range installs {
+			modified, missing, err := checkInstalled(output, &installs[i])
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("fetch", target.pkg.Name, target.version.Version, err))
+			}
+			changed = append(changed, modified...)
+			clean = clean && len(modified) == 0 && len(missing) == 0
+		}
+		upToDate := len(installs) == 1 && installs[0].Version == target.version.Version &&
+			installs[0].Dir == target.dir && installs[0].Digest == target.digest
+		if upToDate && clean {
+			result := models.NewResult("unchanged", target.pkg.Name, target.version.Version, time.Now())
+			result.Digest = installs[0].Digest
+			results = append(results, result)
+			continue
+		}
+		conflicts = append(conflicts, changed...)
+		install = append(install, target)
+	}
+	for _, installed := range db.Packages {
+		if wanted[installed.Name] {
+			continue
+		}
+		modified, _, err := checkInstalled(output, &installed)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("remove", installed.Name, installed.Version, err))
+		}
+		conflicts = append(conflicts, modified...)
+	}
+	if len(conflicts) > 0 {
+		if !force {
+			return results, tracerr.Wrap(fmt.Errorf("%w: locally modified files would be overwritten or removed, use force: %s",
+				models.ErrConflict, strings.Join(conflicts, ", ")))
+		}
+		log.Printf("overwriting locally modified files: %s", strings.Join(conflicts, ", "))
+	}
+	if u.dryRun {
+		for _, target := range install {
+			result, err := u.plannedFetch(target.pkg.Name, target.version.Version, target.version.File, f, time.Now())
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("fetch", target.pkg.Name, target.version.Version, err))
+			}
+			result.Target = target.installDir
+			results = append(results, result)
+		}
+		for _, installed := range db.Packages {
+			if !wanted[installed.Name] {
+				results = append(results, plannedRemoval("remove", installed, time.Now()))
+			}
+		}
+		return results, nil
+	}
+
+	for _, target := range install {
+		started := time.Now()
+		name, ver := target.pkg.Name, target.version.Version
+		log.Println(fmt.Sprintf("syncing %s@%s to %s...", name, ver, target.installDir))
+		ex, err := u.install(name, ver, target.version.File, target.installDir, f)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("fetch", name, ver, err))
+		}
+		files, err := ownedFiles(target.installDir, target.dir, ex)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("fetch", name, ver, err))
+		}
+		err = recordInstall(output, db, models.InstalledPackage{
+			Name:      name,
+			Version:   ver,
+			Digest:    target.digest,
+			Dir:       target.dir,
+			Installed: time.Now().UTC(),
+			Files:     files,
+		}, db.Installs(name))
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("fetch", name, ver, err))
+		}
+		if err = saveInstallDB(output, db); err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("fetch", name, ver, err))
+		}
+
+		result := models.NewResult("fetch", name, ver, started)
+		result.Files = ex.Files
+		result.Bytes = ex.Bytes
+		result.Digest = target.digest
+		results = append(results, result)
+	}
+
+	for _, installed := range append([]models.InstalledPackage{}, db.Packages...) {
+		if wanted[installed.Name] {
+			continue
+		}
+		started := time.Now()
+		log.Println(fmt.Sprintf("removing %s@%s from %s...", installed.Name, installed.Version, output))
+		result, err := u.uninstall(output, db, installed, started)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("remove", installed.Name, installed.Version, err))
+		}
+		result.Action = "remove"
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// syncTarget resolves the highest version of p and where it is installed.
+func (u *PackageManager) syncTarget(p models.Packages, output string) (syncTarget, error) {
+	target := syncTarget{pkg: p}
+	p, err := u.resolveTag(p)
+	if err != nil {
+		return target, tracerr.Wrap(err)
+	}
+	op, needVer := utils.ParseVersionRef(p.Ver)
+	info, err := u.packageInfo(p.Name, needVer, op)
+	if err != nil {
+		return target, tracerr.Wrap(err)
+	}
+	version, ok := latestVersion(info, op)
+	if !ok {
+		return target, tracerr.Wrap(models.ErrNotFound)
+	}
+	target.version = version
+
+	meta, err := u.readMetadata(p.Name, target.version.Version)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return target, tracerr.Wrap(err)
+	}
+	target.digest = meta.Digest
+
+	target.dir, err = p.InstallDir(target.version.Version)
+	if err != nil {
+		return target, tracerr.Wrap(err)
+	}
+	target.dir = path.Clean(filepath.ToSlash(target.dir))
+	target.installDir = filepath.Join(output, filepath.FromSlash(target.dir))
+	return target, nil
+}
+
+// ownedFiles returns the files an installed version owns, with paths relative
+// to the output directory. They are taken from the manifest, or read back
+// from the disk for archives created without one.
+func ownedFiles(installDir, dir string, ex extraction) ([]models.ManifestFile, error) {
+	files := make([]models.ManifestFile, 0, len(ex.Entries))
+	if ex.Manifest != nil {
+		for _, file := range ex.Manifest.Files {
+			file.Path = path.Join(dir, file.Path)
+			files = append(files, file)
+		}
+		return files, nil
+	}
+	for _, entry := range ex.Entries {
+		filepath_ := filepath.Join(installDir, filepath.FromSlash(entry))
+		info, err := os.Lstat(filepath_)
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		file := models.ManifestFile{Path: path.Join(dir, entry), Mode: info.Mode().String()}
+		switch {
+		case info.IsDir():
+		case info.Mode()&fs.ModeSymlink != 0:
+			file.LinkTarget, err = os.Readlink(filepath_)
+			if err != nil {
+				return nil, tracerr.Wrap(err)
+			}
+			file.Digest, file.Size, err = utils.DigestReader(strings.NewReader(file.LinkTarget))
+		default:
+			file.Digest, file.Size, err = utils.DigestFile(filepath_)
+		}
+		if err != nil {
+			return nil, tracerr.Wrap(err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// checkInstalled returns the files of an installed package that were changed
+// or removed since it was installed.
+func checkInstalled(output string, installed *models.InstalledPackage) ([]string, []string, error) {
+	modified, missing := make([]string, 0), make([]string, 0)
+	for _, file := range installed.Files {
+		filepath_ := filepath.Join(output, filepath.FromSlash(file.Path))
+		info, err := os.Lstat(filepath_)
+		if os.IsNotExist(err) {
+			missing = append(missing, file.Path)
+			continue
+		}
+		if err != nil {
+			return nil, nil, tracerr.Wrap(err)
+		}
+		switch {
+		case strings.HasPrefix(file.Mode, "d"):
+			if !info.IsDir() {
+				modified = append(modified, file.Path)
+			}
+		case file.LinkTarget != "":
+			target, err := os.Readlink(filepath_)
+			if err != nil || target != file.LinkTarget {
+				modified = append(modified, file.Path)
+			}
+		case !info.Mode().IsRegular() || info.Size() != file.Size:
+			modified = append(modified, file.Path)
+		default:
+			digest, _, err := utils.DigestFile(filepath_)
+			if err != nil {
+				return nil, nil, tracerr.Wrap(err)
+			}
+			if file.Digest != "" && digest != file.Digest {
+				modified = append(modified, file.Path)
+			}
+		}
+	}
+	return modified, missing, nil
+}
+
+// recordInstall records pkg in the install database of output in place of
+// previous, removing the files of previous that pkg no longer owns.
+func recordInstall(output string, db *models.InstallDB, pkg models.InstalledPackage,
+	previous []models.InstalledPackage) error {
+	for _, installed := range previous {
+		db.Delete(installed.Name, installed.Dir)
+	}
+	// record the new files first so removing the previous versions leaves
+	// the ones they share with pkg
+	db.Put(pkg)
+	for _, installed := range previous {
+		if err := removeOwned(output, db, installed); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return tracerr.Wrap(saveInstallDB(output, db))
+}
+
+// removeOwned removes the files of pkg that no package recorded in db owns.
+func removeOwned(output string, db *models.InstallDB, pkg models.InstalledPackage) error {
+	owners := db.Owners()
+	paths := make([]string, 0, len(pkg.Files))
+	for _, file := range pkg.Files {
+		if len(owners[file.Path]) == 0 {
+			paths = append(paths, file.Path)
+		}
+	}
+	keep := make(map[string]bool, len(owners))
+	for p := range owners {
+		keep[p] = true
+	}
+	return removeFiles(output, paths, keep)
+}
+
+// loadInstallDB reads the install database of output, an empty one if
+// nothing was synced there yet.
+func loadInstallDB(output string) (*models.InstallDB, error) {
+	db := &models.InstallDB{Packages: []models.InstalledPackage{}}
+	bs, err := os.ReadFile(filepath.Join(output, filepath.FromSlash(models.InstallDBPath)))
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	if err = json.Unmarshal(bs, db); err != nil {
+		return nil, tracerr.Wrap(fmt.Errorf("%w: %s: %w", models.ErrIntegrity, models.InstallDBPath, err))
+	}
+	return db, nil
+}
+
+// saveInstallDB replaces the install database of output atomically.
+func saveInstallDB(output string, db *models.InstallDB) error {
+	dbPath := filepath.Join(output, filepath.FromSlash(models.InstallDBPath))
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return tracerr.Wrap(err)
+	}
+	bs, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(writeFileAtomic(dbPath, bs))
+}
+
+// writeFileAtomic replaces file with bs through a temporary file renamed
+// into place, readers see either the old or the new content.
+func writeFileAtomic(file string, bs []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(bs)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(os.Rename(tmp.Name(), file))
+}
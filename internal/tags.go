@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// Tags returns the dist-tags of name.
+func (u *PackageManager) Tags(name string) (models.Tags, error) {
+	tags, err := u.readTags(name)
+	if err != nil {
+		return tags, tracerr.Wrap(models.NewPackageError("tag", name, "", err))
+	}
+	return tags, nil
+}
+
+// Tag points tags of name at the exact version ver, moving them from the
+// version they pointed at before.
+func (u *PackageManager) Tag(name, ver string, tags []string) ([]models.Result, error) {
+	u.resetIndex()
+	results := make([]models.Result, 0, len(tags))
+	op, needVer := utils.ParseVersionRef(ver)
+	if op != utils.EQUAL {
+		return results, tracerr.Wrap(models.NewPackageError("tag", name, ver,
+			fmt.Errorf("%w: an exact version is needed", models.ErrInvalidInput)))
+	}
+	published, err := u.publishedArchive(name, needVer)
+	if err != nil {
+		return results, tracerr.Wrap(models.NewPackageError("tag", name, needVer, err))
+	}
+	if published == "" {
+		return results, tracerr.Wrap(models.NewPackageError("tag", name, needVer, models.ErrNotFound))
+	}
+	return u.tag(name, needVer, tags)
+}
+
+// tag points tags of name at the published version ver.
+func (u *PackageManager) tag(name, ver string, tags []string) ([]models.Result, error) {
+	results := make([]models.Result, 0, len(tags))
+	for _, tag := range tags {
+		if err := models.ValidateTag(tag); err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("tag", name, ver, err))
+		}
+	}
+	err := u.changeTags(name, func(stored models.Tags) {
+		for _, tag := range tags {
+			result := models.NewResult("tag", name, ver, time.Now())
+			result.Target = tag
+			switch {
+			case stored[tag] == ver:
+				result.Action = "unchanged"
+			case u.dryRun:
+				result.Status = models.StatusDryRun
+			default:
+				log.Printf("package: tagging %s@%s as %s", name, ver, tag)
+				stored[tag] = ver
+			}
+			results = append(results, result)
+		}
+	})
+	if err != nil {
+		return results, tracerr.Wrap(models.NewPackageError("tag", name, ver, err))
+	}
+	return results, nil
+}
+
+// Untag removes tags of name, failing with ErrNotFound for a tag it does
+// not have.
+func (u *PackageManager) Untag(name string, tags []string) ([]models.Result, error) {
+	results := make([]models.Result, 0, len(tags))
+	missing := make([]string, 0)
+	err := u.changeTags(name, func(stored models.Tags) {
+		for _, tag := range tags {
+			if _, ok := stored[tag]; !ok {
+				missing = append(missing, tag)
+			}
+		}
+		if len(missing) > 0 {
+			return
+		}
+		for _, tag := range tags {
+			result := models.NewResult("untag", name, stored[tag], time.Now())
+			result.Target = tag
+			if u.dryRun {
+				result.Status = models.StatusDryRun
+			} else {
+				log.Printf("package: removing tag %s of %s@%s", tag, name, stored[tag])
+				delete(stored, tag)
+			}
+			results = append(results, result)
+		}
+	})
+	if err != nil {
+		return results, tracerr.Wrap(models.NewPackageError("untag", name, "", err))
+	}
+	if len(missing) > 0 {
+		return results, tracerr.Wrap(models.NewPackageError("untag", name, "",
+			fmt.Errorf("%w: tag %s", models.ErrNotFound, strings.Join(missing, ", "))))
+	}
+	return results, nil
+}
+
+// changeTags applies change to the stored tags of name holding their lock,
+// and writes them back. Nothing is written in a dry run.
+func (u *PackageManager) changeTags(name string, change func(stored models.Tags)) error {
+	if !u.dryRun {
+		unlock, err := u.lock(models.TagsLock(name))
+		if err != nil {
+			return tracerr.Wrap(err)
+		}
+		defer unlock()
+	}
+	stored, err := u.readTags(name)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	change(stored)
+	if u.dryRun {
+		return nil
+	}
+	bs, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	return tracerr.Wrap(u.client.WriteMeta(path.Join(name, models.TagsName), bs))
+}
+
+func (u *PackageManager) readTags(name string) (models.Tags, error) {
+	tags := models.Tags{}
+	bs, err := u.client.ReadMeta(path.Join(name, models.TagsName))
+	if errors.Is(err, models.ErrNotFound) {
+		return tags, nil
+	}
+	if err != nil {
+		return tags, tracerr.Wrap(err)
+	}
+	if err = json.Unmarshal(bs, &tags); err != nil {
+		return tags, tracerr.Wrap(fmt.Errorf("%w: tags of %s: %w", models.ErrIntegrity, name, err))
+	}
+	return tags, nil
+}
+
+// resolveTag turns a reference to a tag, "@stable", into an exact pin of the
+// version it points at. Other references are returned unchanged.
+func (u *PackageManager) resolveTag(p models.Packages) (models.Packages, error) {
+	tag, ok := models.TagRef(p.Ver)
+	if !ok {
+		return p, nil
+	}
+	tags, err := u.readTags(p.Name)
+	if err != nil {
+		return p, tracerr.Wrap(err)
+	}
+	ver, ok := tags[tag]
+	if !ok {
+		return p, tracerr.Wrap(fmt.Errorf("%w: tag %s", models.ErrNotFound, tag))
+	}
+	log.Printf("package: %s@%s is %s", p.Name, p.Ver, ver)
+	p.Ver = "=" + ver
+	return p, nil
+}
@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// _trash_dir keeps removed versions inside their package directory, as
+// "<name>/.trash/<ver>.<format>" with the metadata in "<name>/.trash/.meta".
+// Being hidden it is never listed as a version.
+const _trash_dir = ".trash"
+
+// trash moves name@ver to the trash of its package until expires. A copy of
+// the same version trashed before is replaced.
+func (u *PackageManager) trash(name, ver, archive string, expires time.Time, f func(rm string) error) error {
+	meta, err := u.readMetadata(name, ver)
+	if errors.Is(err, models.ErrNotFound) {
+		meta = models.Metadata{Name: name, Version: ver}
+	} else if err != nil {
+		return tracerr.Wrap(err)
+	}
+
+	trashed, err := u.trashedArchives(name)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for _, file := range trashed {
+		if trashedVer, _, _ := models.SplitArchiveName(file.Name()); trashedVer == ver {
+			if err = f(path.Join(name, _trash_dir, file.Name())); err != nil {
+				return tracerr.Wrap(err)
+			}
+		}
+	}
+
+	if err = u.client.Move(path.Join(name, archive), path.Join(name, _trash_dir, archive)); err != nil {
+		return tracerr.Wrap(err)
+	}
+	meta.Trashed = time.Now().UTC()
+	meta.Expires = expires
+	return tracerr.Wrap(u.writeMetadataAt(path.Join(name, _trash_dir, ver), meta))
+}
+
+// purgeTrash removes the trashed versions of name that expired before now.
+func (u *PackageManager) purgeTrash(name string, now time.Time, f func(rm string) error) error {
+	trashed, err := u.trashedArchives(name)
+	if err != nil {
+		return tracerr.Wrap(err)
+	}
+	for _, file := range trashed {
+		ver, _, _ := models.SplitArchiveName(file.Name())
+		meta, err := u.readMetadataAt(path.Join(name, _trash_dir, ver))
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			return tracerr.Wrap(err)
+		}
+		if meta.Expires.IsZero() || meta.Expires.After(now) {
+			continue
+		}
+		log.Printf("package: %s@%s expired in the trash, removing it", name, ver)
+		if err = f(path.Join(name, _trash_dir, file.Name())); err != nil {
+			return tracerr.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// trashedArchives lists the archives in the trash of name.
+func (u *PackageManager) trashedArchives(name string) ([]os.FileInfo, error) {
+	files, err := u.client.GetVersions(path.Join(name, _trash_dir))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, tracerr.Wrap(err)
+	}
+	archives := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		if _, _, isArchive := models.SplitArchiveName(file.Name()); isArchive {
+			archives = append(archives, file)
+		}
+	}
+	return archives, nil
+}
+
+// Trashed returns the metadata of the versions in the trash of the packages
+// named, of all packages if names is empty.
+func (u *PackageManager) Trashed(names []string) ([]models.Metadata, error) {
+	trashed := make([]models.Metadata, 0)
+	if len(names) == 0 {
+		packages, err := u.client.GetPackages()
+		if err != nil {
+			return trashed, tracerr.Wrap(err)
+		}
+		for _, p := range packages {
+			names = append(names, p.Name())
+		}
+	}
+	for _, name := range names {
+		archives, err := u.trashedArchives(name)
+		if err != nil {
+			return trashed, tracerr.Wrap(models.NewPackageError("restore", name, "", err))
+		}
+		for _, file := range archives {
+			ver, format, _ := models.SplitArchiveName(file.Name())
+			meta, err := u.readMetadataAt(path.Join(name, _trash_dir, ver))
+			if errors.Is(err, models.ErrNotFound) {
+				meta = models.Metadata{Name: name, Version: ver}
+			} else if err != nil {
+				return trashed, tracerr.Wrap(models.NewPackageError("restore", name, ver, err))
+			}
+			// versions published before metadata was recorded only get
+			// the trash times when removed
+			if meta.Size == 0 {
+				meta.Size, meta.Format = file.Size(), format
+			}
+			trashed = append(trashed, meta)
+		}
+	}
+	return trashed, nil
+}
+
+// Restore moves the trashed versions of refs back into their packages, a
+// version without operator names that version. A version published again
+// since it was removed is not replaced.
+func (u *PackageManager) Restore(refs []models.Packages) ([]models.Result, error) {
+	u.resetIndex()
+	results := make([]models.Result, 0, len(refs))
+	for _, ref := range refs {
+		op, needVer := utils.ParseVersionRef(ref.Ver)
+		archives, err := u.trashedArchives(ref.Name)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError("restore", ref.Name, ref.Ver, err))
+		}
+		matched := 0
+		for _, file := range archives {
+			started := time.Now()
+			ver, _, _ := models.SplitArchiveName(file.Name())
+			ok, err := utils.CompareVersions(ver, needVer, op)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("restore", ref.Name, ref.Ver, fmt.Errorf("%w: %w", models.ErrInvalidInput, err)))
+			}
+			if !ok {
+				continue
+			}
+			matched++
+			if u.dryRun {
+				result := models.NewResult("restore", ref.Name, ver, started)
+				result.Status = models.StatusDryRun
+				result.Bytes = file.Size()
+				result.Target = path.Join(ref.Name, file.Name())
+				results = append(results, result)
+				continue
+			}
+
+			meta, err := u.restore(ref.Name, ver, file)
+			if err != nil {
+				return results, tracerr.Wrap(models.NewPackageError("restore", ref.Name, ver, err))
+			}
+			result := models.NewResult("restore", ref.Name, ver, started)
+			result.Bytes = file.Size()
+			result.Digest = meta.Digest
+			results = append(results, result)
+		}
+		if matched == 0 {
+			return results, tracerr.Wrap(models.NewPackageError("restore", ref.Name, ref.Ver, fmt.Errorf("%w: not in the trash", models.ErrNotFound)))
+		}
+	}
+	return results, nil
+}
+
+// restore moves the trashed archive file of name@ver back into its package
+// holding the version lock, so it can not race a publish of the version.
+func (u *PackageManager) restore(name, ver string, file os.FileInfo) (models.Metadata, error) {
+	unlock, err := u.lock(models.VersionLock(name, ver))
+	if err != nil {
+		return models.Metadata{}, tracerr.Wrap(err)
+	}
+	defer unlock()
+	u.resetIndex()
+	published, err := u.publishedArchive(name, ver)
+	if err != nil {
+		return models.Metadata{}, tracerr.Wrap(err)
+	}
+	if published != "" {
+		return models.Metadata{}, tracerr.Wrap(models.ErrVersionExists)
+	}
+	log.Printf("package: restoring %s@%s from the trash", name, ver)
+	if err = u.client.Move(path.Join(name, _trash_dir, file.Name()), path.Join(name, file.Name())); err != nil {
+		return models.Metadata{}, tracerr.Wrap(err)
+	}
+	meta, err := u.readMetadata(name, ver)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return meta, tracerr.Wrap(err)
+	}
+	if err == nil {
+		meta.Trashed, meta.Expires = time.Time{}, time.Time{}
+		if err = u.writeMetadata(meta); err != nil {
+			return meta, tracerr.Wrap(err)
+		}
+	} else {
+		meta = models.Metadata{Name: name, Version: ver, Size: file.Size(), Uploaded: file.ModTime()}
+	}
+	err = u.updateIndex(func(index *models.Index) {
+		index.Put(name, models.IndexVersionOf(meta, file.Name()))
+	})
+	return meta, tracerr.Wrap(err)
+}
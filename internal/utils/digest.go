@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+)
+
+const DigestAlgorithm = "sha256"
+
+// DigestReader returns the "sha256:<hex>" digest of r and the number of bytes read.
+func DigestReader(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return DigestAlgorithm + ":" + hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// DigestFile returns the digest and size of the file at path.
+func DigestFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	return DigestReader(f)
+}
+
+// DigestWriter computes the digest of everything written to it.
+type DigestWriter struct {
+	hash hash.Hash
+	size int64
+}
+
+func NewDigestWriter() *DigestWriter {
+	return &DigestWriter{hash: sha256.New()}
+}
+
+func (d *DigestWriter) Write(p []byte) (int, error) {
+	n, err := d.hash.Write(p)
+	d.size += int64(n)
+	return n, err
+}
+
+// Digest returns the "sha256:<hex>" digest of the bytes written so far.
+func (d *DigestWriter) Digest() string {
+	return DigestAlgorithm + ":" + hex.EncodeToString(d.hash.Sum(nil))
+}
+
+// Size returns the number of bytes written so far.
+func (d *DigestWriter) Size() int64 {
+	return d.size
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceDateEpochEnv names the environment variable holding the timestamp
+// reproducible builds use instead of the current time, in seconds since the
+// unix epoch. See https://reproducible-builds.org/specs/source-date-epoch/
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// SourceDateEpoch returns the time set in SOURCE_DATE_EPOCH and whether it is set.
+func SourceDateEpoch() (time.Time, bool, error) {
+	value, ok := os.LookupEnv(SourceDateEpochEnv)
+	value = strings.TrimSpace(value)
+	if !ok || value == "" {
+		return time.Time{}, false, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false, fmt.Errorf("%s must be a non-negative integer, got %q", SourceDateEpochEnv, value)
+	}
+	return time.Unix(seconds, 0).UTC(), true, nil
+}
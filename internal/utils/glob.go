@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is read from the working directory when packing and holds
+// exclude patterns, one per line, with .gitignore syntax.
+const IgnoreFileName = ".pmignore"
+
+const doubleStar = "**"
+
+// MatchPath reports whether the slash separated name matches pattern. Besides
+// the path.Match syntax a "**" segment matches any number of path segments.
+func MatchPath(pattern, name string) (bool, error) {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == doubleStar {
+			for i := 0; i <= len(name); i++ {
+				ok, err := matchSegments(pattern[1:], name[i:])
+				if ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// Glob works like filepath.Glob and additionally supports "**" segments,
+// walking the tree under the static prefix of pattern.
+func Glob(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	if !strings.Contains(pattern, doubleStar) {
+		return filepath.Glob(filepath.FromSlash(pattern))
+	}
+	if _, err := MatchPath(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	segments := strings.Split(pattern, "/")
+	static := 0
+	for static < len(segments) && !hasMeta(segments[static]) {
+		static++
+	}
+	root := strings.Join(segments[:static], "/")
+	if root == "" {
+		root = "."
+	}
+	if strings.HasPrefix(pattern, "/") && static == 1 {
+		root = "/"
+	}
+
+	matches := make([]string, 0)
+	err := filepath.WalkDir(filepath.FromSlash(root), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == filepath.FromSlash(root) && os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		ok, err := MatchPath(pattern, filepath.ToSlash(p))
+		if err != nil {
+			return err
+		}
+		if ok {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func hasMeta(segment string) bool {
+	return strings.ContainsAny(segment, `*?[\`)
+}
+
+// Ignore evaluates exclude patterns with .gitignore semantics: patterns are
+// checked in order and the last matching one wins, "!" re-includes a path,
+// a trailing "/" matches directories only, a pattern containing "/" matches
+// the full path and any other pattern matches the base name at any depth.
+// Excluding a directory excludes everything below it.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func NewIgnore(patterns []string) (*Ignore, error) {
+	ignore := &Ignore{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(p, "!") {
+			rule.negate = true
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly = true
+			p = strings.TrimSuffix(p, "/")
+		}
+		rule.anchored = strings.Contains(p, "/")
+		rule.pattern = strings.TrimPrefix(filepath.ToSlash(p), "/")
+		if _, err := MatchPath(rule.pattern, ""); err != nil {
+			return nil, err
+		}
+		ignore.rules = append(ignore.rules, rule)
+	}
+	return ignore, nil
+}
+
+// ReadIgnoreFile returns the patterns of an ignore file, or none if it does not exist.
+func ReadIgnoreFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
+
+// Excluded reports whether name, or one of its parent directories, is excluded.
+func (i *Ignore) Excluded(name string, isDir bool) bool {
+	name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(name)), "./")
+	segments := strings.Split(name, "/")
+	for n := 1; n < len(segments); n++ {
+		if i.match(strings.Join(segments[:n], "/"), true) {
+			return true
+		}
+	}
+	return i.match(name, isDir)
+}
+
+func (i *Ignore) match(name string, isDir bool) bool {
+	excluded := false
+	for _, rule := range i.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := name
+		if !rule.anchored {
+			target = path.Base(name)
+		}
+		if ok, _ := MatchPath(rule.pattern, target); ok {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPath(t *testing.T) {
+	var tests = []struct {
+		name_   string
+		pattern string
+		input   string
+		want    bool
+	}{
+		{name_: "0", pattern: "test/**", input: "test/test2/file1", want: true},
+		{name_: "1", pattern: "test/**/file1", input: "test/file1", want: true},
+		{name_: "2", pattern: "**/*.ext", input: "test/test2/file3.ext", want: true},
+		{name_: "3", pattern: "test/*", input: "test/test2/file1", want: false},
+		{name_: "4", pattern: "test/**/*.ext", input: "test/test2/file1", want: false},
+	}
+	for _, tt := range tests {
+		ok, err := MatchPath(tt.pattern, tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, tt.want, ok, tt.name_) {
+			t.Fatal("responses is not equal")
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	ignore, err := NewIgnore([]string{"*.log", "build/", "!keep.log", "docs/*.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		input string
+		isDir bool
+		want  bool
+	}{
+		{input: "a/b/c.log", want: true},
+		{input: "a/keep.log", want: false},
+		{input: "build", isDir: true, want: true},
+		{input: "build/bin/app", want: true},
+		{input: "build", want: false},
+		{input: "docs/readme.md", want: true},
+		{input: "other/docs/readme.md", want: false},
+	}
+	for _, tt := range tests {
+		if !assert.Equal(t, tt.want, ignore.Excluded(tt.input, tt.isDir), tt.input) {
+			t.Fatal("responses is not equal")
+		}
+	}
+
+	if _, err = NewIgnore([]string{"["}); err == nil {
+		t.Fatal("want error for malformed pattern")
+	}
+}
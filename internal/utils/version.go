@@ -1,6 +1,10 @@
 package utils
 
-import "strconv"
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
 
 const (
 	MORE_THEN = iota
@@ -29,51 +33,121 @@ func ParseVersion(ver string) (int, string) {
 	return ALL, ver
 }
 
+// ParseVersionRef parses the version of a package reference, name@ver. A
+// version without operator names that version exactly, only an empty one
+// matches every version.
+func ParseVersionRef(ver string) (int, string) {
+	op, needVer := ParseVersion(ver)
+	if op == ALL && needVer != "" {
+		return EQUAL, needVer
+	}
+	return op, needVer
+}
+
 func CompareVersions(have, need string, op int) (bool, error) {
-	have_f, err := strconv.ParseFloat(have, 64)
-	if err != nil {
+	if _, err := parseVersionNumber(have); err != nil {
 		return false, err
 	}
 	if need == "" {
 		return true, nil
 	}
-	need_f, err := strconv.ParseFloat(need, 64)
+	cmp, err := CompareVersionNumbers(have, need)
 	if err != nil {
 		return false, err
 	}
 	switch op {
 	case MORE_THEN:
-		if have_f > need_f {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return cmp > 0, nil
 	case MORE_EQUAL_THEN:
-		if have_f >= need_f {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return cmp >= 0, nil
 	case LESS_THEN:
-		if have_f < need_f {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return cmp < 0, nil
 	case LESS_EQUAL_THEN:
-		if have_f <= need_f {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return cmp <= 0, nil
 	case EQUAL:
-		if have_f == need_f {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return cmp == 0, nil
 	case ALL:
 		return true, nil
 	}
 	return false, nil
 }
+
+// CompareVersionNumbers compares two dot separated versions segment by segment,
+// so 1.10 is newer than 1.9 and 3 equals 3.0. A "-suffix" marks a pre-release
+// that sorts before the same version without suffix.
+// Returns -1, 0 or 1 when a is older, equal or newer than b.
+func CompareVersionNumbers(a, b string) (int, error) {
+	va, err := parseVersionNumber(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := parseVersionNumber(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < len(va.segments) || i < len(vb.segments); i++ {
+		sa, sb := va.segment(i), vb.segment(i)
+		if sa != sb {
+			return compareInts(sa, sb), nil
+		}
+	}
+	switch {
+	case va.pre == vb.pre:
+		return 0, nil
+	case va.pre == "":
+		return 1, nil
+	case vb.pre == "":
+		return -1, nil
+	case va.pre < vb.pre:
+		return -1, nil
+	}
+	return 1, nil
+}
+
+// SortVersions sorts versions from oldest to newest, unparsable ones first.
+func SortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		cmp, err := CompareVersionNumbers(versions[i], versions[j])
+		if err == nil {
+			return cmp < 0
+		}
+		_, errI := parseVersionNumber(versions[i])
+		_, errJ := parseVersionNumber(versions[j])
+		if (errI == nil) != (errJ == nil) {
+			return errI != nil
+		}
+		return versions[i] < versions[j]
+	})
+}
+
+type versionNumber struct {
+	segments []int
+	pre      string
+}
+
+func (v versionNumber) segment(i int) int {
+	if i < len(v.segments) {
+		return v.segments[i]
+	}
+	return 0
+}
+
+func parseVersionNumber(ver string) (versionNumber, error) {
+	ret := versionNumber{}
+	ver, ret.pre, _ = strings.Cut(ver, "-")
+	for _, s := range strings.Split(ver, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return ret, err
+		}
+		ret.segments = append(ret.segments, n)
+	}
+	return ret, nil
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
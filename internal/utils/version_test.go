@@ -57,6 +57,25 @@ func TestParseVersion(t *testing.T) {
 	}
 }
 
+func TestParseVersionRef(t *testing.T) {
+	var tests = []struct {
+		input    string
+		op       int
+		clearVer string
+	}{
+		{input: "1.0", op: EQUAL, clearVer: "1.0"},
+		{input: "=1.0", op: EQUAL, clearVer: "1.0"},
+		{input: ">=1.0", op: MORE_EQUAL_THEN, clearVer: "1.0"},
+		{input: "", op: ALL, clearVer: ""},
+	}
+	for _, tt := range tests {
+		op, clear_ := ParseVersionRef(tt.input)
+		if !assert.Equal(t, tt.op, op, tt.input) || !assert.Equal(t, tt.clearVer, clear_, tt.input) {
+			t.Fatal("responses is not equal")
+		}
+	}
+}
+
 func TestIsApplyVersion(t *testing.T) {
 	var tests = []struct {
 		name_ string
@@ -97,3 +116,37 @@ func TestIsApplyVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestCompareVersionNumbers(t *testing.T) {
+	var tests = []struct {
+		name_ string
+		a     string
+		b     string
+		want  int
+	}{
+		{name_: "minor precedence", a: "1.10", b: "1.9", want: 1},
+		{name_: "trailing zeros", a: "3", b: "3.0", want: 0},
+		{name_: "major", a: "2.0", b: "10.0", want: -1},
+		{name_: "pre-release", a: "3.0-rc1", b: "3.0", want: -1},
+		{name_: "pre-release order", a: "3.0-rc2", b: "3.0-rc1", want: 1},
+	}
+	for _, tt := range tests {
+		cmp, err := CompareVersionNumbers(tt.a, tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, tt.want, cmp, tt.name_) {
+			t.Fatal("responses is not equal")
+		}
+	}
+
+	if _, err := CompareVersionNumbers("1.x", "1.0"); err == nil {
+		t.Fatal("want error for non numeric version")
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	versions := []string{"1.10", "2.0", "1.9", "bad", "1.10-rc1", "0.11"}
+	SortVersions(versions)
+	assert.Equal(t, []string{"bad", "0.11", "1.9", "1.10-rc1", "1.10", "2.0"}, versions)
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"PackageManager/internal/models"
+	"PackageManager/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ztrue/tracerr"
+)
+
+// Yank withdraws the exact versions of refs, or brings them back with
+// yanked unset. Yanked versions stay published: ranges no longer resolve to
+// them while exact pins still install them.
+func (u *PackageManager) Yank(refs []models.Packages, yanked bool) ([]models.Result, error) {
+	u.resetIndex()
+	action := "yank"
+	if !yanked {
+		action = "unyank"
+	}
+	results := make([]models.Result, 0, len(refs))
+	for _, ref := range refs {
+		started := time.Now()
+		op, ver := utils.ParseVersionRef(ref.Ver)
+		if op != utils.EQUAL {
+			return results, tracerr.Wrap(models.NewPackageError(action, ref.Name, ref.Ver,
+				fmt.Errorf("%w: an exact version is needed", models.ErrInvalidInput)))
+		}
+		result, err := u.yank(action, ref.Name, ver, yanked, started)
+		if err != nil {
+			return results, tracerr.Wrap(models.NewPackageError(action, ref.Name, ver, err))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// yank sets yanked in the metadata and index of name@ver holding the
+// version lock, so it can not race a publish, removal or restore of it.
+func (u *PackageManager) yank(action, name, ver string, yanked bool, started time.Time) (models.Result, error) {
+	if !u.dryRun {
+		unlock, err := u.lock(models.VersionLock(name, ver))
+		if err != nil {
+			return models.Result{}, tracerr.Wrap(err)
+		}
+		defer unlock()
+		u.resetIndex()
+	}
+	version, err := u.publishedVersion(name, ver)
+	if err != nil {
+		return models.Result{}, tracerr.Wrap(err)
+	}
+	if version == nil {
+		return models.Result{}, tracerr.Wrap(models.ErrNotFound)
+	}
+	meta, err := u.readMetadata(name, ver)
+	if errors.Is(err, models.ErrNotFound) {
+		_, format, _ := models.SplitArchiveName(version.Name())
+		meta = models.Metadata{Name: name, Version: ver, Format: format, Size: version.Size(), Uploaded: version.ModTime()}
+	} else if err != nil {
+		return models.Result{}, tracerr.Wrap(err)
+	}
+
+	result := models.NewResult(action, name, ver, started)
+	result.Bytes = meta.Size
+	result.Digest = meta.Digest
+	switch {
+	case meta.Yanked == yanked:
+		result.Action = "unchanged"
+	case u.dryRun:
+		result.Status = models.StatusDryRun
+	default:
+		log.Printf("package: %s %s@%s", action, name, ver)
+		meta.Yanked = yanked
+		if err = u.writeMetadata(meta); err != nil {
+			return models.Result{}, tracerr.Wrap(err)
+		}
+		err = u.updateIndex(func(index *models.Index) {
+			index.Put(name, models.IndexVersionOf(meta, version.Name()))
+		})
+		if err != nil {
+			return models.Result{}, tracerr.Wrap(err)
+		}
+	}
+	return result, nil
+}
+
+// yanked reports whether name@ver was yanked, from the index when it knows
+// the version.
+func (u *PackageManager) yanked(name, ver string) (bool, error) {
+	indexed, err := u.indexedVersion(name, ver)
+	if err != nil {
+		return false, tracerr.Wrap(err)
+	}
+	if indexed != nil {
+		return indexed.Yanked, nil
+	}
+	meta, err := u.readMetadata(name, ver)
+	if errors.Is(err, models.ErrNotFound) {
+		return false, nil
+	}
+	return meta.Yanked, tracerr.Wrap(err)
+}
+
+// resolvable reports whether version of name matched by a reference with
+// operator op, from utils.ParseVersionRef, is installed. A yanked version is
+// only when pinned exactly, with a warning.
+func resolvable(name string, version models.VersionInfo, op int) bool {
+	if !version.Yanked {
+		return true
+	}
+	if op == utils.EQUAL {
+		log.Printf("warning: %s@%s was yanked, installing it for its exact pin", name, version.Version)
+		return true
+	}
+	log.Printf("package: %s skipping yanked version %s", name, version.Version)
+	return false
+}
+
+// latestVersion returns the highest version of info, the versions matched by
+// a reference with operator op, the reference resolves to.
+func latestVersion(info models.PackageInfo, op int) (models.VersionInfo, bool) {
+	for i := len(info.Versions) - 1; i >= 0; i-- {
+		if resolvable(info.Name, info.Versions[i], op) {
+			return info.Versions[i], true
+		}
+	}
+	return models.VersionInfo{}, false
+}